@@ -1,10 +1,17 @@
 import { useState, useEffect } from 'react';
 
+const awaitingReviewer = (status) => status === 'REVIEW_REQUIRED' || status === 'PENDING_CONFIRMATION';
+
 export default function VerificationDetail({ token, sessionToken, onBack }) {
     const [data, setData] = useState(null);
     const [loading, setLoading] = useState(true);
     const [actionLoading, setActionLoading] = useState(false);
     const [error, setError] = useState(null);
+    const [lockError, setLockError] = useState(null);
+    const [reasonCodes, setReasonCodes] = useState([]);
+    const [reasonCode, setReasonCode] = useState('');
+    const [notes, setNotes] = useState('');
+    const [resubmitSteps, setResubmitSteps] = useState([]);
 
     useEffect(() => {
         const fetchDetail = async () => {
@@ -13,7 +20,17 @@ export default function VerificationDetail({ token, sessionToken, onBack }) {
                     headers: { 'Authorization': `Bearer ${token}` }
                 });
                 if (!res.ok) throw new Error('Failed to fetch session details');
-                setData(await res.json());
+                const detail = await res.json();
+                setData(detail);
+
+                // Lock the case so two reviewers don't decide it at the same time
+                if (awaitingReviewer(detail.session.status)) {
+                    const claim = await fetch(`http://localhost:8080/admin/reviews/claim?token=${sessionToken}`, {
+                        method: 'POST',
+                        headers: { 'Authorization': `Bearer ${token}` }
+                    });
+                    if (!claim.ok) setLockError(await claim.text());
+                }
             } catch (err) {
                 setError(err.message);
             } finally {
@@ -23,21 +40,52 @@ export default function VerificationDetail({ token, sessionToken, onBack }) {
         fetchDetail();
     }, [token, sessionToken]);
 
-    const handleDecision = async (status) => {
+    useEffect(() => {
+        fetch('http://localhost:8080/admin/review-reasons', {
+            headers: { 'Authorization': `Bearer ${token}` }
+        })
+            .then(res => res.ok ? res.json() : { reason_codes: [] })
+            .then(body => setReasonCodes(body.reason_codes || []))
+            .catch(() => setReasonCodes([]));
+    }, [token]);
+
+    const reasonsFor = (status) => reasonCodes.filter(
+        r => !r.applies_to || r.applies_to.length === 0 || r.applies_to.includes(status.toUpperCase())
+    );
+
+    const handleBack = async () => {
+        if (data && awaitingReviewer(data.session.status) && !lockError) {
+            await fetch(`http://localhost:8080/admin/reviews/release?token=${sessionToken}`, {
+                method: 'POST',
+                headers: { 'Authorization': `Bearer ${token}` }
+            }).catch(() => {});
+        }
+        onBack();
+    };
+
+    const handleDecision = async (status, endpoint = 'decision') => {
+        if (!reasonsFor(status).some(r => r.code === reasonCode)) {
+            alert(`Select a reason that justifies ${status.toUpperCase()}`);
+            return;
+        }
         if (!confirm(`Are you sure you want to ${status.toUpperCase()} this verification?`)) return;
 
         setActionLoading(true);
         try {
-            const res = await fetch(`http://localhost:8080/admin/sessions/decision?token=${sessionToken}`, {
+            const res = await fetch(`http://localhost:8080/admin/sessions/${endpoint}?token=${sessionToken}`, {
                 method: 'POST',
                 headers: {
                     'Authorization': `Bearer ${token}`,
                     'Content-Type': 'application/json'
                 },
-                body: JSON.stringify({ status, reason: "Manual admin review" })
+                body: JSON.stringify({ status, reason_code: reasonCode, notes })
             });
 
-            if (!res.ok) throw new Error('Failed to submit decision');
+            if (!res.ok) throw new Error((await res.text()) || 'Failed to submit decision');
+            const result = await res.json();
+            if (result.status === 'PENDING_CONFIRMATION') {
+                alert('Decision recorded. A second reviewer must confirm it.');
+            }
 
             // Go back to list on success
             onBack();
@@ -47,11 +95,49 @@ export default function VerificationDetail({ token, sessionToken, onBack }) {
         }
     };
 
+    const toggleResubmitStep = (stepId) => {
+        setResubmitSteps(steps => steps.includes(stepId) ? steps.filter(s => s !== stepId) : [...steps, stepId]);
+    };
+
+    const handleResubmission = async () => {
+        if (resubmitSteps.length === 0) {
+            alert('Select the steps the user must redo');
+            return;
+        }
+        if (!reasonsFor('resubmit').some(r => r.code === reasonCode)) {
+            alert('Select a reason that justifies a resubmission');
+            return;
+        }
+        if (!confirm('Send this verification back to the user?')) return;
+
+        setActionLoading(true);
+        try {
+            const res = await fetch(`http://localhost:8080/admin/sessions/request-resubmission?token=${sessionToken}`, {
+                method: 'POST',
+                headers: {
+                    'Authorization': `Bearer ${token}`,
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ step_ids: resubmitSteps, reason_code: reasonCode, notes })
+            });
+
+            if (!res.ok) throw new Error((await res.text()) || 'Failed to request resubmission');
+            const result = await res.json();
+            prompt('Resubmission requested. Link sent to the tenant webhook:', result.url);
+            onBack();
+        } catch (err) {
+            alert(err.message);
+            setActionLoading(false);
+        }
+    };
+
     if (loading) return <div className="p-8 text-center text-gray-500">Loading details...</div>;
     if (error) return <div className="p-8 text-center text-red-600">Error: {error}</div>;
     if (!data) return null;
 
-    const { session, images } = data;
+    const { session, evidence, review } = data;
+    const canDecide = awaitingReviewer(session.status) && !lockError;
+    const canOverride = session.status === 'APPROVED' || session.status === 'REJECTED';
 
     return (
         <div className="bg-white shadow overflow-hidden sm:rounded-lg">
@@ -65,47 +151,51 @@ export default function VerificationDetail({ token, sessionToken, onBack }) {
                         Reference: {session.user_reference}
                     </p>
                 </div>
-                <button onClick={onBack} className="text-sm text-indigo-600 hover:text-indigo-900 cursor-pointer">
+                <button onClick={handleBack} className="text-sm text-indigo-600 hover:text-indigo-900 cursor-pointer">
                     &larr; Back to List
                 </button>
             </div>
 
-            {/* Images Grid */}
-            <div className="bg-gray-50 px-4 py-5 sm:p-6">
-                <div className="grid grid-cols-1 gap-6 sm:grid-cols-2">
-                    {/* Front ID */}
-                    <div className="space-y-2">
-                        <h4 className="font-medium text-gray-900">Front ID</h4>
-                        <div className="aspect-[4/3] bg-gray-200 rounded-lg overflow-hidden border border-gray-300 shadow-sm flex items-center justify-center">
-                            {images?.document_front ? (
-                                <img
-                                    src={images.document_front}
-                                    alt="Front Document"
-                                    className="object-cover w-full h-full"
-                                />
-                            ) : (
-                                <span className="text-gray-400 text-sm">No Image</span>
-                            )}
-                        </div>
-                    </div>
-
-                    {/* Selfie */}
-                    {/* Add null check specifically for images object */}
-                    <div className="space-y-2">
-                        <h4 className="font-medium text-gray-900">Selfie</h4>
-                        <div className="aspect-[3/4] bg-gray-200 rounded-lg overflow-hidden border border-gray-300 shadow-sm flex items-center justify-center relative">
-                            {images?.selfie ? (
-                                <img
-                                    src={images.selfie}
-                                    alt="Selfie"
-                                    className="object-cover w-full h-full"
-                                />
-                            ) : (
-                                <span className="text-gray-400 text-sm">No Image</span>
-                            )}
+            {/* Evidence, grouped by the flow step that collected it, then what earlier rounds had */}
+            <div className="bg-gray-50 px-4 py-5 sm:p-6 space-y-6">
+                {(evidence || []).length === 0 && (
+                    <p className="text-center text-gray-400 text-sm">No evidence collected</p>
+                )}
+                {(evidence || []).map(group => (
+                    <div key={`${group.round || 0}-${group.step_id || 'other'}`} className={group.round ? 'space-y-2 opacity-75' : 'space-y-2'}>
+                        <h4 className="font-medium text-gray-900">{group.label}</h4>
+                        <div className="grid grid-cols-1 gap-6 sm:grid-cols-2">
+                            {group.items.filter(item => item.kind === 'file').map(item => (
+                                <div key={item.key} className="space-y-1">
+                                    <div className="aspect-[4/3] bg-gray-200 rounded-lg overflow-hidden border border-gray-300 shadow-sm flex items-center justify-center">
+                                        {item.url && item.content_type.startsWith('image/') ? (
+                                            <a href={item.url} target="_blank" rel="noreferrer" className="w-full h-full">
+                                                <img src={item.url} alt={item.key} className="object-cover w-full h-full" />
+                                            </a>
+                                        ) : item.url ? (
+                                            <a href={item.url} target="_blank" rel="noreferrer" className="text-sm text-indigo-600">Open file</a>
+                                        ) : (
+                                            <span className="text-gray-400 text-sm">No Image</span>
+                                        )}
+                                    </div>
+                                    <p className="text-xs text-gray-500">{item.key} &middot; {item.content_type}</p>
+                                </div>
+                            ))}
                         </div>
+                        {group.items.some(item => item.kind === 'data') && (
+                            <dl className="grid grid-cols-1 gap-x-4 gap-y-2 sm:grid-cols-2 text-sm">
+                                {group.items.filter(item => item.kind === 'data').map(item => (
+                                    <div key={item.key}>
+                                        <dt className="text-gray-500">{item.key}</dt>
+                                        <dd className="text-gray-900 break-all">
+                                            {typeof item.value === 'object' ? JSON.stringify(item.value) : String(item.value)}
+                                        </dd>
+                                    </div>
+                                ))}
+                            </dl>
+                        )}
                     </div>
-                </div>
+                ))}
             </div>
 
             {/* Data & Actions */}
@@ -121,8 +211,75 @@ export default function VerificationDetail({ token, sessionToken, onBack }) {
                     </div>
                 </dl>
 
-                {session.status.toLowerCase() === 'review_required' && (
-                    <div className="mt-8 flex justify-end space-x-4 pt-4 border-t border-gray-100">
+                {lockError && (
+                    <div className="mt-4 rounded-md bg-yellow-50 p-3 text-sm text-yellow-800">{lockError}</div>
+                )}
+
+                {session.status === 'PENDING_CONFIRMATION' && review?.proposed_status && (
+                    <div className="mt-4 rounded-md bg-blue-50 p-3 text-sm text-blue-800">
+                        Proposed decision: <span className="font-bold">{review.proposed_status}</span>. Another reviewer must confirm or overturn it.
+                    </div>
+                )}
+
+                {(canDecide || canOverride) && (
+                    <div className="mt-8 space-y-3 pt-4 border-t border-gray-100">
+                        <select
+                            value={reasonCode}
+                            onChange={(e) => setReasonCode(e.target.value)}
+                            className="block w-full rounded-md border-gray-300 text-sm"
+                        >
+                            <option value="">Select a reason...</option>
+                            {reasonCodes.map(r => (
+                                <option key={r.code} value={r.code}>{r.label}</option>
+                            ))}
+                        </select>
+                        <textarea
+                            value={notes}
+                            onChange={(e) => setNotes(e.target.value)}
+                            placeholder="Notes (optional)"
+                            rows={2}
+                            className="block w-full rounded-md border-gray-300 text-sm"
+                        />
+                    </div>
+                )}
+
+                {canOverride && (
+                    <div className="mt-4 flex justify-end">
+                        <button
+                            onClick={() => handleDecision(session.status === 'APPROVED' ? 'rejected' : 'approved', 'override')}
+                            disabled={actionLoading}
+                            className="inline-flex justify-center py-2 px-4 border border-gray-300 shadow-sm text-sm font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 disabled:opacity-50 cursor-pointer"
+                        >
+                            Override to {session.status === 'APPROVED' ? 'Rejected' : 'Approved'}
+                        </button>
+                    </div>
+                )}
+
+                {canDecide && session.status === 'REVIEW_REQUIRED' && data.steps?.length > 0 && (
+                    <div className="mt-4 flex flex-wrap items-center justify-end gap-3">
+                        <span className="text-sm text-gray-500">Redo steps:</span>
+                        {data.steps.map(step => (
+                            <label key={step.step_id} className="inline-flex items-center gap-1 text-sm text-gray-700">
+                                <input
+                                    type="checkbox"
+                                    checked={resubmitSteps.includes(step.step_id)}
+                                    onChange={() => toggleResubmitStep(step.step_id)}
+                                />
+                                {step.label || step.step_id}
+                            </label>
+                        ))}
+                        <button
+                            onClick={handleResubmission}
+                            disabled={actionLoading}
+                            className="inline-flex justify-center py-2 px-4 border border-gray-300 shadow-sm text-sm font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 disabled:opacity-50 cursor-pointer"
+                        >
+                            Request Resubmission
+                        </button>
+                    </div>
+                )}
+
+                {canDecide && (
+                    <div className="mt-4 flex justify-end space-x-4">
                         <button
                             onClick={() => handleDecision('rejected')}
                             disabled={actionLoading}
@@ -139,6 +296,45 @@ export default function VerificationDetail({ token, sessionToken, onBack }) {
                         </button>
                     </div>
                 )}
+
+                {data.duplicates?.length > 0 && (
+                    <div className="mt-8 pt-4 border-t border-gray-100">
+                        <h4 className="text-sm font-medium text-red-600 mb-2">Linked Sessions</h4>
+                        <ul className="space-y-1 text-sm text-gray-700">
+                            {data.duplicates.map(d => (
+                                <li key={`${d.kind}-${d.session_id}`}>
+                                    <span className="font-medium">{d.kind.replace(/_/g, ' ')}</span>{' '}
+                                    {d.distance > 0 ? `(similar, distance ${d.distance})` : '(exact)'} &middot;{' '}
+                                    {d.user_reference} &middot; {d.status}{' '}
+                                    <span className="text-gray-400">{new Date(d.created_at).toLocaleString()}</span>
+                                </li>
+                            ))}
+                        </ul>
+                    </div>
+                )}
+
+                {data.events?.length > 0 && (
+                    <div className="mt-8 pt-4 border-t border-gray-100">
+                        <h4 className="text-sm font-medium text-gray-500 mb-2">History</h4>
+                        <ul className="space-y-1 text-sm text-gray-700">
+                            {data.events.map(e => (
+                                <li key={e.id}>
+                                    <span className="text-gray-400">{new Date(e.created_at).toLocaleString()}</span>{' '}
+                                    {e.kind && e.kind !== 'status_change' ? (
+                                        <span className="font-medium">Device {e.kind === 'handoff_completed' ? 'changed' : 'handoff requested'}</span>
+                                    ) : (
+                                        <>
+                                            {e.from_status || 'CREATED'} &rarr; <span className="font-medium">{e.to_status}</span>{' '}
+                                            by {e.actor_type}{e.actor_id ? ` (${e.actor_id})` : ''}
+                                        </>
+                                    )}
+                                    {e.reason_code && <> &middot; {e.reason_code}</>}
+                                    {e.notes && <span className="text-gray-500"> &middot; {e.notes}</span>}
+                                </li>
+                            ))}
+                        </ul>
+                    </div>
+                )}
             </div>
         </div>
     );
@@ -1,14 +1,18 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
+import { streamEvents } from '../shared/stream';
 
 export default function VerificationList({ token, onSelectSession }) {
     const [sessions, setSessions] = useState([]);
     const [loading, setLoading] = useState(true);
     const [error, setError] = useState(null);
     const [search, setSearch] = useState("");
+    const [reload, setReload] = useState(0);
+    const liveReload = useRef(false);
 
     useEffect(() => {
         const fetchSessions = async () => {
-            setLoading(true);
+            if (!liveReload.current) setLoading(true);
+            liveReload.current = false;
             try {
                 const query = search ? `?search=${encodeURIComponent(search)}` : '';
                 const res = await fetch(`http://localhost:8080/admin/sessions${query}`, {
@@ -28,7 +32,25 @@ export default function VerificationList({ token, onSelectSession }) {
             fetchSessions();
         }, 500);
         return () => clearTimeout(timeoutId);
-    }, [token, search]);
+    }, [token, search, reload]);
+
+    // Live updates: progress changes in place, new sessions and status changes
+    // reload the list (a resubmission also changes the token)
+    useEffect(() => {
+        const controller = new AbortController();
+        streamEvents('http://localhost:8080/admin/sessions/stream', { 'Authorization': `Bearer ${token}` }, (event, change) => {
+            if (event !== 'session') return;
+            if (change.event === 'session.created' || change.event === 'session.status_changed') {
+                liveReload.current = true;
+                setReload(n => n + 1);
+                return;
+            }
+            setSessions(prev => prev.map(s => s.id === change.session_id
+                ? { ...s, current_step_index: change.current_step_index, updated_at: change.updated_at }
+                : s));
+        }, controller.signal);
+        return () => controller.abort();
+    }, [token]);
 
     const getStatusBadge = (status) => {
         const normalizedStatus = status.toLowerCase();
@@ -36,7 +58,8 @@ export default function VerificationList({ token, onSelectSession }) {
             'pending': 'bg-yellow-100 text-yellow-800',
             'approved': 'bg-green-100 text-green-800',
             'rejected': 'bg-red-100 text-red-800',
-            'review_required': 'bg-blue-100 text-blue-800'
+            'review_required': 'bg-blue-100 text-blue-800',
+            'pending_confirmation': 'bg-indigo-100 text-indigo-800'
         };
         const colorClass = colors[normalizedStatus] || 'bg-gray-100 text-gray-800';
         return (
@@ -17,7 +17,12 @@ export default function FlowEditor({ token, flow, onSave, onCancel }) {
             const payload = {
                 name,
                 description,
-                steps_configuration: stepsConfig
+                steps_configuration: stepsConfig,
+                // Not editable here yet, keep the saved rules and texts
+                decision_policy: flow ? flow.decision_policy : undefined,
+                review_policy: flow ? flow.review_policy : undefined,
+                default_locale: flow ? flow.default_locale : undefined,
+                translations: flow ? flow.translations : undefined
             };
 
             const url = flow
@@ -83,6 +88,20 @@ export default function FlowEditor({ token, flow, onSave, onCancel }) {
                     />
                 </div>
 
+                <div>
+                    <label className="block text-sm font-medium text-gray-700">Decision rules</label>
+                    <p className="mt-1 text-sm text-gray-500">
+                        {flow && flow.decision_policy && flow.decision_policy.rules && flow.decision_policy.rules.length
+                            ? `${flow.decision_policy.rules.length} rule(s), default ${flow.decision_policy.default || 'REVIEW_REQUIRED'}.`
+                            : 'Default policy: the biometric decision settles the session, everything else goes to review.'}
+                    </p>
+                    <p className="mt-1 text-xs text-gray-500">
+                        Conditions read the engine results (<code>biometrics.decision</code>, <code>document.expired</code>),
+                        {' '}<code>risk.high</code>, <code>risk.codes</code>, <code>metadata.&lt;key&gt;</code> and what
+                        the user submitted under <code>data.&lt;field&gt;</code>, e.g. <code>data.country == "MX"</code>.
+                    </p>
+                </div>
+
                 <div className="flex justify-end space-x-3">
                     <button
                         type="button"
@@ -0,0 +1,38 @@
+const RETRY_DELAY = 5000;
+
+// Reads a Server-Sent Events stream with fetch, so the request can carry
+// headers (EventSource cannot send Authorization). onEvent receives the event
+// name and its parsed data. Reconnects until the signal is aborted or access
+// is denied.
+export async function streamEvents(url, headers, onEvent, signal) {
+    while (!signal.aborted) {
+        try {
+            const res = await fetch(url, { headers, signal });
+            if (res.status === 401 || res.status === 403) return;
+            if (!res.ok) throw new Error(`Stream failed: ${res.status}`);
+
+            const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+            let buffer = '';
+            for (;;) {
+                const { value, done } = await reader.read();
+                if (done) break;
+                buffer += value;
+                let end;
+                while ((end = buffer.indexOf('\n\n')) >= 0) {
+                    const block = buffer.slice(0, end);
+                    buffer = buffer.slice(end + 2);
+                    let event = 'message';
+                    let data = '';
+                    for (const line of block.split('\n')) {
+                        if (line.startsWith('event:')) event = line.slice(6).trim();
+                        else if (line.startsWith('data:')) data += line.slice(5).trim();
+                    }
+                    if (data) onEvent(event, JSON.parse(data));
+                }
+            }
+        } catch {
+            if (signal.aborted) return;
+        }
+        await new Promise((resolve) => setTimeout(resolve, RETRY_DELAY));
+    }
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/aoricaan/idv-core/internal/config"
 	"github.com/aoricaan/idv-core/internal/handler"
 	"github.com/aoricaan/idv-core/internal/infra"
 	"github.com/aoricaan/idv-core/internal/service"
@@ -19,7 +20,12 @@ func main() {
 	if err != nil {
 		log.Fatalf("DB Init failed: %v", err)
 	}
-	// rdb, _ := infra.InitRedis() // Using DB for MVP session first
+	// Redis is optional: rate limits and session streams fall back to
+	// per-replica memory without it
+	rdb, err := infra.InitRedis()
+	if err != nil {
+		log.Printf("WARNING: %v, rate limits and session streams are kept in memory", err)
+	}
 
 	repo := infra.NewRepository(db)
 
@@ -33,9 +39,31 @@ func main() {
 	}
 
 	storageService := service.NewStorageService(blobStorage)
+	webhookService := service.NewWebhookService()
+	imageProcessor := service.NewImageProcessor(storageService)
+
+	ocrProvider, ocrFixturesDir := config.GetOCRConfig()
+	ocrRegistry := service.NewOCRRegistry(ocrProvider)
+	ocrRegistry.Register(service.NewLocalOCRProvider(ocrFixturesDir))
+
+	biometricsProvider, mockMatchScore, mockLivenessScore := config.GetBiometricsConfig()
+	biometricsRegistry := service.NewBiometricsRegistry(biometricsProvider)
+	switch biometricsProvider {
+	case "":
+		log.Println("WARNING: No BIOMETRICS_PROVIDER configured, face match steps will go to manual review")
+	case "mock":
+		// Fixed scores, for local development only
+		log.Println("WARNING: Using the mock biometrics provider, face match results are not real")
+		mockBiometrics := service.NewMockBiometricsProvider(mockMatchScore, mockLivenessScore)
+		biometricsRegistry.RegisterMatcher(mockBiometrics)
+		biometricsRegistry.RegisterLivenessChecker(mockBiometrics)
+	}
 
-	sessionHandler := &handler.SessionHandler{Repo: repo, Storage: storageService}
-	adminHandler := &handler.AdminHandler{Repo: repo, Storage: storageService}
+	sessionStream := service.NewSessionStream(rdb)
+
+	sessionHandler := &handler.SessionHandler{Repo: repo, Storage: storageService, Webhooks: webhookService, Images: imageProcessor, OCR: ocrRegistry, Biometrics: biometricsRegistry, Limiter: service.NewRateLimiter(rdb), Stream: sessionStream}
+	reviewLock, reviewSLA := config.GetReviewConfig()
+	adminHandler := &handler.AdminHandler{Repo: repo, Storage: storageService, Webhooks: webhookService, ReviewLock: reviewLock, ReviewSLA: reviewSLA, Stream: sessionStream}
 	templateHandler := handler.NewTemplateHandler(repo)
 
 	// 2. Routes
@@ -157,6 +185,122 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}))
 
+	http.HandleFunc("/admin/redirect-settings", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.GetRedirectSettings(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UpdateRedirectSettings(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/branding", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.GetBranding(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UpdateBranding(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/branding/logo", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UploadLogo(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/webhook", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.GetWebhookSettings(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UpdateWebhookSettings(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/custom-domain", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.GetCustomDomain(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UpdateCustomDomain(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/custom-domain/verify", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.VerifyCustomDomain(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
 	// Flow Routes
 	http.HandleFunc("/admin/flows", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
@@ -179,6 +323,22 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}))
 
+	http.HandleFunc("/admin/flows/validate", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.ValidateFlow(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
 	http.HandleFunc("/admin/flows/detail", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -211,6 +371,22 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}))
 
+	http.HandleFunc("/admin/flows/simulate-policy", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.SimulatePolicy(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
 	http.HandleFunc("/admin/flows/delete", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -266,7 +442,7 @@ func main() {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-Fingerprint, X-Session-Secret")
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -287,7 +463,7 @@ func main() {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-Fingerprint, X-Session-Secret")
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -299,12 +475,21 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	})
 
+	// Server-to-server: tenant backend fetches the outcome (API key auth)
+	http.HandleFunc("/api/v1/sessions/result", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			sessionHandler.GetSessionResult(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+
 	http.HandleFunc("/api/v1/sessions/upload-url", func(w http.ResponseWriter, r *http.Request) {
 		// CORS Preflight
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-Fingerprint, X-Session-Secret")
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -316,6 +501,57 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	})
 
+	http.HandleFunc("/api/v1/sessions/handoff", func(w http.ResponseWriter, r *http.Request) {
+		// CORS Preflight
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-Fingerprint, X-Session-Secret")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			sessionHandler.CreateHandoff(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	http.HandleFunc("/api/v1/sessions/progress", func(w http.ResponseWriter, r *http.Request) {
+		// CORS Preflight
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-Secret")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			sessionHandler.GetSessionProgress(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	http.HandleFunc("/api/v1/sessions/events", func(w http.ResponseWriter, r *http.Request) {
+		// CORS Preflight
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-Secret")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			sessionHandler.StreamSession(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+
 	http.HandleFunc("/admin/sessions", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -332,6 +568,14 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}))
 
+	http.HandleFunc("/admin/sessions/stream", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.StreamSessions(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
 	http.HandleFunc("/admin/sessions/detail", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		// Using query param ?token=... style for simplicity with http.ServeMux
 		if r.Method == http.MethodOptions {
@@ -365,6 +609,145 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}))
 
+	http.HandleFunc("/admin/sessions/override", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.OverrideSession(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/sessions/request-resubmission", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.RequestResubmission(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/rate-limits", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.GetRateLimits(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UpdateRateLimits(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Review Queue Routes
+	http.HandleFunc("/admin/review-reasons", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.GetReviewReasons(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.UpdateReviewReasons(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/reviews", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.ListReviewQueue(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/reviews/claim", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.ClaimSession(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/reviews/release", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.ReleaseSession(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/admin/reviews/assign", handler.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			adminHandler.AssignSession(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
 	// 3. Start
 	if err := http.ListenAndServe(":8080", nil); err != nil {
 		log.Fatalf("Server failed: %v", err)
@@ -10,6 +10,13 @@ CREATE TABLE IF NOT EXISTS tenants (
     webhook_url TEXT,
     branding_config JSONB DEFAULT '{}',
     credits_balance INT DEFAULT 0,
+    allowed_redirect_domains TEXT[] DEFAULT '{}', -- Hosts accepted for success_url/failure_url
+    signing_secret VARCHAR(64) DEFAULT replace(uuid_generate_v4()::text || uuid_generate_v4()::text, '-', ''), -- HMAC key for return redirects and webhooks
+    custom_domain VARCHAR(255), -- e.g. verify.acme.com, serves the Secure Flow for this tenant once verified
+    custom_domain_token VARCHAR(64), -- Expected in the _idv-verification TXT record of the domain
+    custom_domain_verified_at TIMESTAMP WITH TIME ZONE, -- NULL until the TXT record is found
+    review_reasons JSONB DEFAULT '[]', -- Reason code taxonomy for review decisions, empty uses the built-in one
+    rate_limits JSONB DEFAULT '{}', -- Session creation and Secure Flow request limits, zero uses the default
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
@@ -30,28 +37,100 @@ CREATE TABLE IF NOT EXISTS flows (
     name VARCHAR(255) NOT NULL,
     description TEXT,
     steps_configuration JSONB NOT NULL, -- Array of StepConfig
+    default_locale VARCHAR(10) DEFAULT 'en',
+    translations JSONB DEFAULT '{}', -- locale -> key -> text, referenced from step configs as "$t:key"
+    decision_policy JSONB DEFAULT '{}', -- Rules deciding finished sessions, empty uses the biometric decision
+    review_policy JSONB DEFAULT '{}', -- Four-eyes and override rules for manual review
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
 -- Enum for Session Status
-CREATE TYPE session_status AS ENUM ('PENDING', 'IN_PROGRESS', 'REVIEW_REQUIRED', 'APPROVED', 'REJECTED', 'EXPIRED');
+CREATE TYPE session_status AS ENUM ('PENDING', 'IN_PROGRESS', 'REVIEW_REQUIRED', 'PENDING_CONFIRMATION', 'APPROVED', 'REJECTED', 'EXPIRED');
 
 -- Table: sessions
 CREATE TABLE IF NOT EXISTS sessions (
     token VARCHAR(64) PRIMARY KEY, -- Unique public token for frontend
+    id UUID NOT NULL UNIQUE DEFAULT uuid_generate_v4(), -- Stable ID shared with the client (never the token)
     flow_id UUID REFERENCES flows(id),
     user_reference VARCHAR(255), -- Client's user ID
     current_step_index INT DEFAULT 0,
     status session_status DEFAULT 'PENDING',
-    collected_data JSONB DEFAULT '{}', -- Encrypted metadata/results
+    collected_data JSONB DEFAULT '{}', -- What the user submitted in the Secure Flow
+    results JSONB DEFAULT '{}', -- What the engine worked out (risk signals, device...), never written by the user
+    locale VARCHAR(10), -- e.g. es-MX, pt-BR. Falls back to the flow default
+    metadata JSONB DEFAULT '{}', -- Opaque tenant key/values, echoed back in results and webhooks
+    success_url TEXT, -- Where the user returns after finishing the flow
+    failure_url TEXT, -- Where the user returns if the session is rejected or expires
+    review_started_at TIMESTAMP WITH TIME ZONE, -- Entered REVIEW_REQUIRED, the review SLA counts from here
+    assigned_to UUID, -- tenant_users.id of the reviewer it was assigned to
+    locked_by UUID, -- Reviewer that claimed it, until locked_until
+    locked_until TIMESTAMP WITH TIME ZONE,
+    reviewed_by UUID, -- Reviewer that decided it
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    proposed_status session_status, -- First decision of a four-eyes review
+    proposed_by UUID,
+    proposed_at TIMESTAMP WITH TIME ZONE,
+    device_secret_hash VARCHAR(64), -- SHA-256 of the secret of the device the session is bound to
+    device_bound_at TIMESTAMP WITH TIME ZONE,
+    handoff_code_hash VARCHAR(64), -- One-time code to continue on another device
+    handoff_expires_at TIMESTAMP WITH TIME ZONE,
+    watcher_secret_hash VARCHAR(64), -- Device that handed the session over, can follow its progress
     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Table: session_events
+-- Audit trail of every status transition of a session
+CREATE TABLE IF NOT EXISTS session_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL DEFAULT 'status_change', -- status_change, handoff_created, handoff_completed
+    from_status VARCHAR(32), -- NULL when the session is created
+    to_status VARCHAR(32) NOT NULL,
+    actor_type VARCHAR(20) NOT NULL, -- system, reviewer, api_key, device
+    actor_id VARCHAR(255), -- tenant_users.id for reviewers, key last 4 for API keys
+    reason_code VARCHAR(100), -- Tenant taxonomy, only on status changes
+    notes TEXT,
+    proposed_status VARCHAR(32), -- Decision awaiting a second reviewer
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Table: session_accesses
+-- Who called the Secure Flow endpoints of a session, for device and network risk signals
+CREATE TABLE IF NOT EXISTS session_accesses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    endpoint VARCHAR(32) NOT NULL, -- get_session, submit_step, upload_url, handoff
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512),
+    accept_language VARCHAR(255),
+    device_id VARCHAR(128), -- Fingerprint sent by the client (X-Device-Fingerprint)
+    country VARCHAR(2), -- From the proxy geolocation header, when configured
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Table: session_fingerprints
+-- Hashes of a session's images and identity fields, to find the same document
+-- or person reused across sessions of a tenant
+CREATE TABLE IF NOT EXISTS session_fingerprints (
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    kind VARCHAR(32) NOT NULL, -- document_image, selfie_image, document_number, curp
+    value VARCHAR(64) NOT NULL, -- Perceptual hash (hex) or SHA-256 of the normalized field
+    phash BIGINT, -- Perceptual hash of images, compared by Hamming distance
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (session_id, kind, value)
+);
+
 -- Metadata for quick tenant lookup
 CREATE INDEX idx_tenants_api_key_hash ON tenants(api_key_hash);
+CREATE UNIQUE INDEX idx_tenants_custom_domain ON tenants(custom_domain) WHERE custom_domain_verified_at IS NOT NULL; -- Claims are free until proven
+CREATE INDEX idx_sessions_review_queue ON sessions(status, review_started_at);
+CREATE INDEX idx_session_events_session ON session_events(session_id, created_at);
+CREATE INDEX idx_session_accesses_session ON session_accesses(session_id, created_at);
+CREATE INDEX idx_session_fingerprints_lookup ON session_fingerprints(tenant_id, kind, value);
 
 -- Table: tenant_users
 CREATE TABLE IF NOT EXISTS tenant_users (
@@ -73,6 +152,7 @@ CREATE TABLE IF NOT EXISTS step_templates (
     description TEXT,
     strategy VARCHAR(50) NOT NULL, -- UI_STEP or CODE_STEP
     base_config JSONB DEFAULT '{}',
+    translations JSONB DEFAULT '{}', -- locale -> key -> text
     is_system BOOLEAN DEFAULT FALSE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
@@ -83,6 +163,8 @@ INSERT INTO step_templates (slug, name, description, strategy, base_config, is_s
 VALUES 
     ('document_scan', 'Document Scan', 'Scan ID document using device camera', 'UI_STEP', '{}', TRUE),
     ('selfie_capture', 'Selfie Capture', 'Capture user selfie for liveness check', 'UI_STEP', '{}', TRUE),
-    ('face_match', 'Face Match', 'Compare ID photo with Selfie', 'CODE_STEP', '{"endpoint": "/internal/face-match"}', TRUE),
-    ('instructions', 'Instructions', 'Display instructions to the user', 'UI_STEP', '{}', TRUE)
+    ('face_match', 'Face Match', 'Compare ID photo with Selfie', 'CODE_STEP', '{}', TRUE),
+    ('instructions', 'Instructions', 'Display instructions to the user', 'UI_STEP', '{}', TRUE),
+    ('curp_validation', 'CURP Validation', 'Validate the CURP and cross-check the date of birth', 'CODE_STEP', '{}', TRUE),
+    ('barcode_decode', 'Barcode Decode', 'Read the PDF417 barcode on the back of the ID and compare it with the front', 'CODE_STEP', '{}', TRUE)
 ON CONFLICT (slug) DO NOTHING;
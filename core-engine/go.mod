@@ -9,6 +9,7 @@ require (
 	github.com/minio/minio-go/v7 v7.0.98
 	github.com/redis/go-redis/v9 v9.17.2
 	golang.org/x/crypto v0.47.0
+	golang.org/x/image v0.35.0
 )
 
 require (
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func GetJWTSecret() []byte {
@@ -14,6 +17,15 @@ func GetJWTSecret() []byte {
 	return []byte(secret)
 }
 
+// GetSecureFlowBaseURL returns the public URL of the Secure Flow web app (no trailing slash)
+func GetSecureFlowBaseURL() string {
+	baseURL := os.Getenv("SECURE_FLOW_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000" // Default for local dev
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
 func GetMinIOConfig() (string, string, string, string) {
 	internalEndpoint := os.Getenv("MINIO_ENDPOINT") // Internal (docker network): "minio:9000"
 	if internalEndpoint == "" {
@@ -35,3 +47,47 @@ func GetMinIOConfig() (string, string, string, string) {
 	}
 	return internalEndpoint, publicEndpoint, accessKey, secretKey
 }
+
+// GetOCRConfig returns the default OCR provider name ("" disables OCR unless a
+// step selects a provider) and the fixtures directory of the local provider.
+func GetOCRConfig() (string, string) {
+	return os.Getenv("OCR_PROVIDER"), os.Getenv("OCR_FIXTURES_DIR")
+}
+
+// GetBiometricsConfig returns the default face match/liveness provider and the
+// scores returned by the mock provider. There is no default: without a
+// provider face match steps go to review, and the mock only runs when
+// BIOMETRICS_PROVIDER is explicitly "mock".
+func GetBiometricsConfig() (string, float64, float64) {
+	return os.Getenv("BIOMETRICS_PROVIDER"), getFloat("MOCK_FACE_MATCH_SCORE", 0.9), getFloat("MOCK_LIVENESS_SCORE", 0.9)
+}
+
+// GetReviewConfig returns how long a claimed session stays locked to its reviewer
+// and how long a session may wait for review before it breaches the SLA.
+func GetReviewConfig() (time.Duration, time.Duration) {
+	lock := getFloat("REVIEW_LOCK_MINUTES", 15) * float64(time.Minute)
+	sla := getFloat("REVIEW_SLA_HOURS", 24) * float64(time.Hour)
+	return time.Duration(lock), time.Duration(sla)
+}
+
+// TrustProxyHeaders reports whether the engine runs behind a proxy that sets
+// X-Forwarded-For, so the client IP is read from it instead of the connection
+func TrustProxyHeaders() bool {
+	trust, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS"))
+	return trust
+}
+
+// GetCountryHeader returns the header where the proxy puts the client country
+// (e.g. CF-IPCountry), "" when there is no geolocation. Only read together with
+// TrustProxyHeaders.
+func GetCountryHeader() string {
+	return os.Getenv("GEOIP_COUNTRY_HEADER")
+}
+
+func getFloat(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -25,15 +25,59 @@ func (j *JSONB) Scan(value interface{}) error {
 }
 
 type Tenant struct {
-	ID             uuid.UUID `json:"id"`
-	Name           string    `json:"name"`
-	APIKeyHash     string    `json:"-"` // Never expose hash
-	APIKeyLast4    string    `json:"api_key_last_4,omitempty"`
-	WebhookURL     string    `json:"webhook_url"`
-	BrandingConfig JSONB     `json:"branding_config"`
-	CreditsBalance int       `json:"credits_balance"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID                     uuid.UUID       `json:"id"`
+	Name                   string          `json:"name"`
+	APIKeyHash             string          `json:"-"` // Never expose hash
+	APIKeyLast4            string          `json:"api_key_last_4,omitempty"`
+	WebhookURL             string          `json:"webhook_url"`
+	BrandingConfig         JSONB           `json:"branding_config"`
+	CreditsBalance         int             `json:"credits_balance"`
+	AllowedRedirectDomains []string        `json:"allowed_redirect_domains"`
+	SigningSecret          string          `json:"-"` // Signs return redirects and webhooks
+	CustomDomain           string          `json:"custom_domain,omitempty"`
+	RateLimits             RateLimitPolicy `json:"rate_limits"`
+	CreatedAt              time.Time       `json:"created_at"`
+	UpdatedAt              time.Time       `json:"updated_at"`
+}
+
+// CustomDomainClaim is the custom domain a tenant asked for. It only serves
+// the Secure Flow once the challenge TXT record has been found.
+type CustomDomainClaim struct {
+	Domain     string
+	Token      string
+	VerifiedAt *time.Time
+}
+
+// RateLimitPolicy caps how fast sessions can be created with a tenant's API key
+// and how often its Secure Flow can be called. Zero uses the default limit.
+type RateLimitPolicy struct {
+	SessionsPerMinute       int `json:"sessions_per_minute"`                 // Per API key
+	SessionsPerUserPerDay   int `json:"sessions_per_user_reference_per_day"` // Per user_reference
+	PublicRequestsPerMinute int `json:"public_requests_per_ip_per_minute"`   // Secure Flow endpoints, per end-user IP
+}
+
+func (p RateLimitPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *RateLimitPolicy) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &p)
+}
+
+// Branding is the typed view of Tenant.BrandingConfig used to white-label the Secure Flow
+type Branding struct {
+	PrimaryColor     string            `json:"primary_color,omitempty"`
+	SecondaryColor   string            `json:"secondary_color,omitempty"`
+	BackgroundColor  string            `json:"background_color,omitempty"`
+	TextColor        string            `json:"text_color,omitempty"`
+	LogoKey          string            `json:"logo_key,omitempty"` // Object key in blob storage
+	FontFamily       string            `json:"font_family,omitempty"`
+	CopyOverrides    map[string]string `json:"copy_overrides,omitempty"` // e.g. "welcome.title" -> "Verify with Acme"
+	PrivacyPolicyURL string            `json:"privacy_policy_url,omitempty"`
 }
 
 type CreditTransaction struct {
@@ -61,17 +105,36 @@ const (
 	StrategyCodeStep StepStrategy = "CODE_STEP"
 )
 
+// Translations is a localized string table: locale -> key -> text
+type Translations map[string]map[string]string
+
+func (t Translations) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *Translations) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &t)
+}
+
 type StepTemplate struct {
-	ID          uuid.UUID    `json:"id"`
-	TenantID    *uuid.UUID   `json:"tenant_id,omitempty"`
-	Slug        string       `json:"slug"`
-	Name        string       `json:"name"`
-	Description string       `json:"description"`
-	Strategy    StepStrategy `json:"strategy"`
-	BaseConfig  JSONB        `json:"base_config"`
-	IsSystem    bool         `json:"is_system"`
-	CreatedAt   time.Time    `json:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at"`
+	ID           uuid.UUID    `json:"id"`
+	TenantID     *uuid.UUID   `json:"tenant_id,omitempty"`
+	Slug         string       `json:"slug"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Strategy     StepStrategy `json:"strategy"`
+	BaseConfig   JSONB        `json:"base_config"`
+	Translations Translations `json:"translations"`
+	IsSystem     bool         `json:"is_system"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
 }
 
 type StepConfig struct {
@@ -99,13 +162,67 @@ func (s *StepsConfig) Scan(value interface{}) error {
 }
 
 type Flow struct {
-	ID                 uuid.UUID   `json:"id"`
-	TenantID           uuid.UUID   `json:"tenant_id"`
-	Name               string      `json:"name"`
-	Description        string      `json:"description"`
-	StepsConfiguration StepsConfig `json:"steps_configuration"`
-	CreatedAt          time.Time   `json:"created_at"`
-	UpdatedAt          time.Time   `json:"updated_at"`
+	ID                 uuid.UUID      `json:"id"`
+	TenantID           uuid.UUID      `json:"tenant_id"`
+	Name               string         `json:"name"`
+	Description        string         `json:"description"`
+	StepsConfiguration StepsConfig    `json:"steps_configuration"`
+	DefaultLocale      string         `json:"default_locale"`
+	Translations       Translations   `json:"translations"`
+	DecisionPolicy     DecisionPolicy `json:"decision_policy"`
+	ReviewPolicy       ReviewPolicy   `json:"review_policy"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// DecisionPolicy decides the status of a session when its last step is done.
+// Every rule whose condition holds votes for its decision; REJECTED wins over
+// REVIEW_REQUIRED, which wins over APPROVED. Without any match Default applies.
+type DecisionPolicy struct {
+	Rules   []DecisionRule `json:"rules"`
+	Default SessionStatus  `json:"default,omitempty"` // REVIEW_REQUIRED when empty
+}
+
+type DecisionRule struct {
+	ID       string        `json:"id"`
+	When     string        `json:"when"` // Condition over the session facts, e.g. biometrics.face_match.score >= 0.9
+	Decision SessionStatus `json:"decision"`
+	Reason   string        `json:"reason"`
+}
+
+func (p DecisionPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *DecisionPolicy) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &p)
+}
+
+// ReviewPolicy configures dual control of manual review decisions
+type ReviewPolicy struct {
+	// A second reviewer must confirm rejections
+	FourEyesRejections bool `json:"four_eyes_rejections"`
+	// A second reviewer must confirm approvals of sessions with a risk signal of
+	// this severity or higher (low, medium, high); empty never requires it
+	FourEyesApprovalRisk string `json:"four_eyes_approval_risk,omitempty"`
+	// Roles allowed to override an APPROVED or REJECTED decision, ADMIN when empty
+	OverrideRoles []string `json:"override_roles,omitempty"`
+}
+
+func (p ReviewPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *ReviewPolicy) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &p)
 }
 
 type SessionStatus string
@@ -117,16 +234,169 @@ const (
 	StatusRejected   SessionStatus = "REJECTED"
 	StatusExpired    SessionStatus = "EXPIRED"
 	StatusReview     SessionStatus = "REVIEW_REQUIRED"
+	// A reviewer decided, a second one must confirm or overturn it (four-eyes)
+	StatusPendingConfirmation SessionStatus = "PENDING_CONFIRMATION"
 )
 
+// DecisionResubmit is a review decision that sends the session back to the
+// user to redo some steps. Sessions are never stored with it, they go back to
+// IN_PROGRESS.
+const DecisionResubmit SessionStatus = "RESUBMIT"
+
+// IsFinal reports whether the user has nothing left to do in the flow
+func (s SessionStatus) IsFinal() bool {
+	switch s {
+	case StatusReview, StatusPendingConfirmation, StatusApproved, StatusRejected, StatusExpired:
+		return true
+	}
+	return false
+}
+
 type Session struct {
+	ID               uuid.UUID     `json:"id"`
 	Token            string        `json:"token"`
 	FlowID           uuid.UUID     `json:"flow_id"`
 	UserReference    string        `json:"user_reference"`
 	CurrentStepIndex int           `json:"current_step_index"`
 	Status           SessionStatus `json:"status"`
-	CollectedData    JSONB         `json:"collected_data"`
+	CollectedData    JSONB         `json:"collected_data"`    // Submitted by the user
+	Results          JSONB         `json:"results,omitempty"` // Engine results, the user can't write them
+	Metadata         JSONB         `json:"metadata,omitempty"`
+	Locale           string        `json:"locale,omitempty"`
+	SuccessURL       string        `json:"success_url,omitempty"`
+	FailureURL       string        `json:"failure_url,omitempty"`
 	ExpiresAt        time.Time     `json:"expires_at"`
 	CreatedAt        time.Time     `json:"created_at"`
 	UpdatedAt        time.Time     `json:"updated_at"`
+	// Secure Flow device the session is bound to, empty until first opened
+	DeviceSecretHash string `json:"-"`
+	// Device that handed the session over to the current one
+	WatcherSecretHash string `json:"-"`
+}
+
+// AcceptsSubmissions reports whether the user can still submit steps: the
+// session is pending or in progress and has not expired
+func (s *Session) AcceptsSubmissions(now time.Time) bool {
+	return (s.Status == StatusPending || s.Status == StatusInProgress) && now.Before(s.ExpiresAt)
+}
+
+// Who caused a session event
+const (
+	ActorSystem   = "system"
+	ActorReviewer = "reviewer"
+	ActorAPIKey   = "api_key"
+	ActorDevice   = "device" // Secure Flow device moves, the status does not change
+)
+
+// Kinds of session events, device moves never carry a reason code
+const (
+	EventStatusChange      = "status_change"
+	DeviceHandoffCreated   = "handoff_created"
+	DeviceHandoffCompleted = "handoff_completed"
+)
+
+// SessionEvent is a status transition in the audit trail of a session, or a
+// device move (ActorDevice) where FromStatus and ToStatus are the same
+type SessionEvent struct {
+	ID         uuid.UUID     `json:"id"`
+	SessionID  uuid.UUID     `json:"session_id"`
+	Kind       string        `json:"kind"`
+	FromStatus SessionStatus `json:"from_status,omitempty"` // Empty when the session is created
+	ToStatus   SessionStatus `json:"to_status"`
+	ActorType  string        `json:"actor_type"`
+	ActorID    string        `json:"actor_id,omitempty"` // Reviewer user ID or API key last 4
+	ReasonCode string        `json:"reason_code,omitempty"`
+	Notes      string        `json:"notes,omitempty"`
+	// Decision a reviewer proposed, set when moving to PENDING_CONFIRMATION
+	ProposedStatus SessionStatus `json:"proposed_status,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// SessionAccess is a call to a Secure Flow endpoint of a session
+type SessionAccess struct {
+	ID             uuid.UUID `json:"id"`
+	SessionID      uuid.UUID `json:"session_id"`
+	Endpoint       string    `json:"endpoint"` // get_session, submit_step, upload_url, handoff
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	AcceptLanguage string    `json:"accept_language,omitempty"`
+	DeviceID       string    `json:"device_id,omitempty"` // Client supplied fingerprint
+	Country        string    `json:"country,omitempty"`   // ISO 3166-1 alpha-2
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Fingerprint kinds
+const (
+	FingerprintDocumentImage  = "document_image"
+	FingerprintSelfieImage    = "selfie_image"
+	FingerprintDocumentNumber = "document_number"
+	FingerprintCURP           = "curp"
+)
+
+// Fingerprint identifies an artifact of a session without storing it: the
+// perceptual hash of an image or the hash of a normalized identity field
+type Fingerprint struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+	PHash *int64 `json:"-"` // Images only
+}
+
+// DuplicateMatch is another session of the tenant sharing a fingerprint
+type DuplicateMatch struct {
+	Kind          string        `json:"kind"`
+	SessionID     uuid.UUID     `json:"session_id"`
+	Token         string        `json:"token"`
+	UserReference string        `json:"user_reference"`
+	Status        SessionStatus `json:"status"`
+	Distance      int           `json:"distance"` // Differing bits of the perceptual hash, 0 for exact matches
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// ReasonCode is an entry of the tenant taxonomy that justifies review decisions
+type ReasonCode struct {
+	Code      string          `json:"code"`
+	Label     string          `json:"label"`
+	AppliesTo []SessionStatus `json:"applies_to,omitempty"` // Decisions it can justify, any when empty
+}
+
+type ReasonCodes []ReasonCode
+
+func (c ReasonCodes) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *ReasonCodes) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &c)
+}
+
+// ReviewState is the manual review bookkeeping of a session
+type ReviewState struct {
+	ReviewStartedAt *time.Time `json:"review_started_at,omitempty"`
+	AssignedTo      *uuid.UUID `json:"assigned_to,omitempty"`
+	LockedBy        *uuid.UUID `json:"locked_by,omitempty"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty"`
+	ReviewedBy      *uuid.UUID `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	// First decision of a four-eyes review, awaiting confirmation by someone else
+	ProposedStatus *SessionStatus `json:"proposed_status,omitempty"`
+	ProposedBy     *uuid.UUID     `json:"proposed_by,omitempty"`
+	ProposedAt     *time.Time     `json:"proposed_at,omitempty"`
+}
+
+// ReviewQueueItem is a session waiting for a reviewer
+type ReviewQueueItem struct {
+	Token         string        `json:"token"`
+	FlowID        uuid.UUID     `json:"flow_id"`
+	FlowName      string        `json:"flow_name"`
+	UserReference string        `json:"user_reference"`
+	Status        SessionStatus `json:"status"` // REVIEW_REQUIRED or PENDING_CONFIRMATION
+	CreatedAt     time.Time     `json:"created_at"`
+	ReviewState
 }
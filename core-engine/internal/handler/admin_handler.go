@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -20,8 +23,12 @@ import (
 )
 
 type AdminHandler struct {
-	Repo    *infra.Repository
-	Storage *service.StorageService
+	Repo       *infra.Repository
+	Storage    *service.StorageService
+	Webhooks   *service.WebhookService
+	ReviewLock time.Duration          // How long a claimed session stays locked
+	ReviewSLA  time.Duration          // Time a session may wait for review
+	Stream     *service.SessionStream // Optional, nil disables real-time streams
 }
 
 type LoginRequest struct {
@@ -109,6 +116,12 @@ func (h *AdminHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 3. Prepare Tenant Data (API Key generated later on demand)
+	signingSecret, err := service.NewSigningSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate signing secret", http.StatusInternalServerError)
+		return
+	}
+
 	tenantID := uuid.New()
 	tenantName := req.CompanyName
 	if tenantName == "" {
@@ -123,6 +136,9 @@ func (h *AdminHandler) Register(w http.ResponseWriter, r *http.Request) {
 		WebhookURL:     "",
 		BrandingConfig: domain.JSONB{"primary_color": "#4F46E5"},
 		CreditsBalance: 10,
+
+		AllowedRedirectDomains: []string{},
+		SigningSecret:          signingSecret,
 	}
 
 	// 5. Prepare User Data
@@ -236,317 +252,1339 @@ func (h *AdminHandler) GetAPIKeyStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Credits Response
-type CreditsResponse struct {
-	Balance      int                        `json:"balance"`
-	Transactions []domain.CreditTransaction `json:"transactions"`
+// ----------------------------------------
+// Return Redirect Settings
+// ----------------------------------------
+
+type RedirectSettingsRequest struct {
+	AllowedDomains []string `json:"allowed_domains"`
 }
 
-func (h *AdminHandler) GetCredits(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, proxy-revalidate")
-	w.Header().Set("Pragma", "no-cache")
-	w.Header().Set("Expires", "0")
+type RedirectSettingsResponse struct {
+	AllowedDomains []string `json:"allowed_domains"`
+	SigningSecret  string   `json:"signing_secret"` // Used by the tenant to verify the redirect signature
+}
 
-	tenantID := r.Context().Value("tenant_id").(string)
+func (h *AdminHandler) GetRedirectSettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	// 1. Get Tenant for Balance
 	tenant, err := h.Repo.GetTenantByID(tenantID)
 	if err != nil {
 		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	// 2. Get Transactions
-	txs, err := h.Repo.GetCreditTransactions(tenantID, 5) // Last 5
-	if err != nil {
-		http.Error(w, "Failed to fetch transactions", http.StatusInternalServerError)
-		return
+	domains := tenant.AllowedRedirectDomains
+	if domains == nil {
+		domains = []string{}
 	}
 
-	json.NewEncoder(w).Encode(CreditsResponse{
-		Balance:      tenant.CreditsBalance,
-		Transactions: txs,
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RedirectSettingsResponse{
+		AllowedDomains: domains,
+		SigningSecret:  tenant.SigningSecret,
 	})
 }
 
-// Request for adding credits
-type AddCreditsRequest struct {
-	Amount      int    `json:"amount"`
-	Description string `json:"description"`
-}
-
-func (h *AdminHandler) AddCredits(w http.ResponseWriter, r *http.Request) {
-	tenantID := r.Context().Value("tenant_id").(string)
-
-	var req AddCreditsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+func (h *AdminHandler) UpdateRedirectSettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	err := h.Repo.AddCredits(tenantID, req.Amount, req.Description)
-	if err != nil {
-		http.Error(w, "Failed to add credits", http.StatusInternalServerError)
+	var req RedirectSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
-
-func (h *AdminHandler) SimulateUsage(w http.ResponseWriter, r *http.Request) {
-	tenantID := r.Context().Value("tenant_id").(string)
+	// Normalize and de-duplicate
+	domains := []string{}
+	seen := make(map[string]bool)
+	for _, raw := range req.AllowedDomains {
+		d, err := service.NormalizeDomain(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
 
-	// Deduct 1 credit
-	err := h.Repo.AddCredits(tenantID, -1, "Verification Usage (Simulation)")
-	if err != nil {
-		http.Error(w, "Failed to deduct credit", http.StatusInternalServerError)
+	if err := h.Repo.UpdateTenantRedirectSettings(tenantID, domains); err != nil {
+		log.Printf("ERROR: Failed to update redirect settings: %v", err)
+		http.Error(w, "Failed to update redirect settings", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.GetRedirectSettings(w, r)
 }
 
 // ----------------------------------------
-// Admin Review Endpoints
+// Branding
 // ----------------------------------------
 
-func (h *AdminHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
-	tenantID, _ := r.Context().Value("tenant_id").(string)
-	search := r.URL.Query().Get("search")
-
-	sessions, err := h.Repo.ListSessions(tenantID, 50, search)
-	if err != nil {
-		fmt.Printf("ERROR: Failed to list sessions: %v\n", err)
-		http.Error(w, fmt.Sprintf("Failed to list sessions: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(sessions)
-}
-
-type SessionReviewResponse struct {
-	Session  *domain.Session   `json:"session"`
-	Images   map[string]string `json:"images"` // Presigned URLs
-	TenantID string            `json:"tenant_id"`
+type BrandingResponse struct {
+	Branding domain.Branding `json:"branding"`
+	LogoURL  string          `json:"logo_url,omitempty"` // Presigned preview of the current logo
 }
 
-func (h *AdminHandler) GetSessionReview(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Missing token", http.StatusBadRequest)
+func (h *AdminHandler) GetBranding(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	session, err := h.Repo.GetSessionByToken(token)
+	tenant, err := h.Repo.GetTenantByID(tenantID)
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	// 2. Generate Presigned GET URLs for artifacts
-	images := make(map[string]string)
-
-	// Helper to sign if exists
-	sign := func(keyName string) {
-		if val, ok := session.CollectedData[keyName].(string); ok && val != "" {
-			url, err := h.Storage.GeneratePresignedGetURL(r.Context(), val)
-			if err == nil {
-				images[keyName] = url
-			}
+	branding := service.BrandingFromConfig(tenant.BrandingConfig)
+	resp := BrandingResponse{Branding: branding}
+	if branding.LogoKey != "" {
+		if logoURL, err := h.Storage.GeneratePresignedGetURL(r.Context(), branding.LogoKey); err == nil {
+			resp.LogoURL = logoURL
 		}
 	}
 
-	sign("document_front")
-	sign("selfie")
-
-	resp := SessionReviewResponse{
-		Session:  session,
-		Images:   images,
-		TenantID: session.FlowID.String(), // Approximate
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-type ReviewDecisionRequest struct {
-	Status string `json:"status"` // approved, rejected
-	Reason string `json:"reason"`
-}
+func (h *AdminHandler) UpdateBranding(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-func (h *AdminHandler) DecideSession(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Missing token", http.StatusBadRequest)
+	tenant, err := h.Repo.GetTenantByID(tenantID)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	var req ReviewDecisionRequest
+	var req domain.Branding
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validate status
-	upperStatus := strings.ToUpper(req.Status)
-	status := domain.SessionStatus(upperStatus)
-	if status != domain.StatusApproved && status != domain.StatusRejected {
-		http.Error(w, "Invalid status", http.StatusBadRequest)
+	branding, err := service.SanitizeBranding(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Update DB
-	err := h.Repo.UpdateSessionStatus(token, status)
-	if err != nil {
-		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+	// The logo is only changed through the upload endpoint
+	branding.LogoKey = service.BrandingFromConfig(tenant.BrandingConfig).LogoKey
+
+	if err := h.saveBranding(tenantID, branding); err != nil {
+		http.Error(w, "Failed to update branding", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.GetBranding(w, r)
 }
 
-// ----------------------------------------
-// Flow Management Endpoints
-// ----------------------------------------
-
-func (h *AdminHandler) ListFlows(w http.ResponseWriter, r *http.Request) {
+// UploadLogo accepts a multipart "logo" file and stores it in blob storage
+func (h *AdminHandler) UploadLogo(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := r.Context().Value("tenant_id").(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	flows, err := h.Repo.ListFlows(tenantID)
+	tenant, err := h.Repo.GetTenantByID(tenantID)
 	if err != nil {
-		log.Printf("ERROR: Failed to list flows: %v", err)
-		http.Error(w, "Failed to list flows", http.StatusInternalServerError)
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(flows)
-}
+	// 1. Read file (size limited)
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxLogoSize+4096)
+	file, _, err := r.FormFile("logo")
+	if err != nil {
+		http.Error(w, "Missing logo file or file too large", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
 
-func (h *AdminHandler) GetFlow(w http.ResponseWriter, r *http.Request) {
-	flowID := r.URL.Query().Get("id")
-	if flowID == "" {
-		http.Error(w, "Missing flow ID", http.StatusBadRequest)
+	data, err := io.ReadAll(io.LimitReader(file, service.MaxLogoSize+1))
+	if err != nil || len(data) == 0 || len(data) > service.MaxLogoSize {
+		http.Error(w, "Logo must be at most 1MB", http.StatusBadRequest)
 		return
 	}
 
-	flow, err := h.Repo.GetFlowByID(flowID)
-	if err != nil {
-		http.Error(w, "Flow not found", http.StatusNotFound)
+	// 2. Sniff type (never trust the client provided one)
+	contentType := http.DetectContentType(data)
+	ext, allowed := service.AllowedLogoTypes[contentType]
+	if !allowed {
+		http.Error(w, "Logo must be PNG, JPEG or WebP", http.StatusBadRequest)
 		return
 	}
 
-	// Verify Tenant Access
-	tenantID, _ := r.Context().Value("tenant_id").(string)
-	if flow.TenantID.String() != tenantID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// 3. Upload
+	objectKey := fmt.Sprintf("%s/branding/logo-%s.%s", tenantID, uuid.New().String(), ext)
+	if err := h.Storage.PutObject(r.Context(), objectKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		log.Printf("ERROR: Failed to upload logo: %v", err)
+		http.Error(w, "Failed to upload logo", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(flow)
+	// 4. Save
+	branding := service.BrandingFromConfig(tenant.BrandingConfig)
+	branding.LogoKey = objectKey
+	if err := h.saveBranding(tenantID, branding); err != nil {
+		http.Error(w, "Failed to update branding", http.StatusInternalServerError)
+		return
+	}
+
+	h.GetBranding(w, r)
 }
 
-type CreateFlowRequest struct {
-	Name               string             `json:"name"`
-	Description        string             `json:"description"`
-	StepsConfiguration domain.StepsConfig `json:"steps_configuration"`
+func (h *AdminHandler) saveBranding(tenantID string, branding domain.Branding) error {
+	config, err := service.BrandingToConfig(branding)
+	if err != nil {
+		return err
+	}
+	if err := h.Repo.UpdateTenantBranding(tenantID, config); err != nil {
+		log.Printf("ERROR: Failed to update branding: %v", err)
+		return err
+	}
+	return nil
 }
 
-func (h *AdminHandler) CreateFlow(w http.ResponseWriter, r *http.Request) {
+type WebhookSettings struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+func (h *AdminHandler) GetWebhookSettings(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := r.Context().Value("tenant_id").(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var req CreateFlowRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	tenant, err := h.Repo.GetTenantByID(tenantID)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	if req.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookSettings{WebhookURL: tenant.WebhookURL})
+}
+
+func (h *AdminHandler) UpdateWebhookSettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	tID, err := uuid.Parse(tenantID)
-	if err != nil {
-		http.Error(w, "Invalid Tenant ID", http.StatusInternalServerError)
+	var req WebhookSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	flow := &domain.Flow{
-		ID:                 uuid.New(),
-		TenantID:           tID,
-		Name:               req.Name,
-		Description:        req.Description,
-		StepsConfiguration: req.StepsConfiguration,
-		CreatedAt:          time.Now(),
-		UpdatedAt:          time.Now(),
+	if req.WebhookURL != "" {
+		if err := service.CheckWebhookURL(req.WebhookURL); errors.Is(err, service.ErrWebhookTarget) {
+			http.Error(w, "Webhook URL must point to a public address", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Invalid webhook URL", http.StatusBadRequest)
+			return
+		}
 	}
 
-	if err := h.Repo.CreateFlow(flow); err != nil {
-		log.Printf("ERROR: Failed to create flow: %v", err)
-		http.Error(w, "Failed to create flow", http.StatusInternalServerError)
+	if err := h.Repo.UpdateTenantWebhookURL(tenantID, req.WebhookURL); err != nil {
+		log.Printf("ERROR: Failed to update webhook: %v", err)
+		http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(flow)
+	h.GetWebhookSettings(w, r)
 }
 
-func (h *AdminHandler) UpdateFlow(w http.ResponseWriter, r *http.Request) {
-	flowIDStr := r.URL.Query().Get("id")
-	if flowIDStr == "" {
-		http.Error(w, "Missing flow ID", http.StatusBadRequest)
-		return
-	}
+type CustomDomainRequest struct {
+	Domain string `json:"domain"` // Empty string removes the custom domain
+}
+
+type CustomDomainResponse struct {
+	Domain     string     `json:"domain"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	TXTName    string     `json:"txt_name,omitempty"` // DNS TXT record to create to prove ownership
+	TXTValue   string     `json:"txt_value,omitempty"`
+	FlowURL    string     `json:"flow_url"` // Base the Secure Flow links will use
+}
 
+func (h *AdminHandler) GetCustomDomain(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := r.Context().Value("tenant_id").(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Check existence and ownership
-	existingFlow, err := h.Repo.GetFlowByID(flowIDStr)
+	claim, err := h.Repo.GetTenantCustomDomain(tenantID)
 	if err != nil {
-		http.Error(w, "Flow not found", http.StatusNotFound)
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	if existingFlow.TenantID.String() != tenantID {
+	resp := CustomDomainResponse{
+		Domain:     claim.Domain,
+		Verified:   claim.VerifiedAt != nil,
+		VerifiedAt: claim.VerifiedAt,
+		FlowURL:    config.GetSecureFlowBaseURL(),
+	}
+	if claim.Domain != "" {
+		resp.TXTName, resp.TXTValue = service.CustomDomainChallenge(claim.Domain, claim.Token)
+	}
+	if resp.Verified {
+		resp.FlowURL = service.FlowBaseURL(config.GetSecureFlowBaseURL(), claim.Domain)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *AdminHandler) UpdateCustomDomain(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var req CreateFlowRequest // Reusing struct
+	var req CustomDomainRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	existingFlow.Name = req.Name
-	existingFlow.Description = req.Description
-	existingFlow.StepsConfiguration = req.StepsConfiguration
-	existingFlow.UpdatedAt = time.Now()
+	customDomain := ""
+	if strings.TrimSpace(req.Domain) != "" {
+		d, err := service.NormalizeDomain(req.Domain)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		customDomain = d
+	}
 
-	if err := h.Repo.UpdateFlow(existingFlow); err != nil {
-		log.Printf("ERROR: Failed to update flow: %v", err)
-		http.Error(w, "Failed to update flow", http.StatusInternalServerError)
+	// Domains are unique across tenants once verified
+	if customDomain != "" {
+		if owner, err := h.Repo.GetTenantByCustomDomain(customDomain); err == nil && owner.ID.String() != tenantID {
+			http.Error(w, "Domain already registered", http.StatusConflict)
+			return
+		}
+	}
+
+	// Asking again for the same domain keeps its challenge (and verification)
+	claim, err := h.Repo.GetTenantCustomDomain(tenantID)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
+	if claim.Domain != customDomain {
+		token := ""
+		if customDomain != "" {
+			if token, err = service.NewSigningSecret(); err != nil {
+				http.Error(w, "Failed to create verification token", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := h.Repo.UpdateTenantCustomDomain(tenantID, customDomain, token); err != nil {
+			log.Printf("ERROR: Failed to update custom domain: %v", err)
+			http.Error(w, "Failed to update custom domain", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(existingFlow)
+	h.GetCustomDomain(w, r)
+}
+
+// VerifyCustomDomain checks the challenge TXT record of the claimed domain.
+// Links only use the domain once it is verified.
+func (h *AdminHandler) VerifyCustomDomain(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(TenantIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	claim, err := h.Repo.GetTenantCustomDomain(tenantID)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+	if claim.Domain == "" {
+		http.Error(w, "No custom domain to verify", http.StatusBadRequest)
+		return
+	}
+	if claim.VerifiedAt == nil {
+		if err := service.VerifyCustomDomain(r.Context(), claim.Domain, claim.Token); errors.Is(err, service.ErrDomainNotVerified) {
+			name, value := service.CustomDomainChallenge(claim.Domain, claim.Token)
+			http.Error(w, fmt.Sprintf("TXT record %s with value %s not found", name, value), http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			log.Printf("WARNING: Custom domain verification of %s failed: %v", claim.Domain, err)
+			http.Error(w, "DNS lookup failed, try again later", http.StatusBadGateway)
+			return
+		}
+
+		verified, err := h.Repo.VerifyTenantCustomDomain(tenantID, claim.Domain)
+		if infra.IsUniqueViolation(err) {
+			http.Error(w, "Domain already registered", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to verify custom domain: %v", err)
+			http.Error(w, "Failed to verify custom domain", http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			http.Error(w, "The custom domain changed meanwhile", http.StatusConflict)
+			return
+		}
+	}
+
+	h.GetCustomDomain(w, r)
+}
+
+// Credits Response
+type CreditsResponse struct {
+	Balance      int                        `json:"balance"`
+	Transactions []domain.CreditTransaction `json:"transactions"`
+}
+
+func (h *AdminHandler) GetCredits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, proxy-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+
+	tenantID := r.Context().Value("tenant_id").(string)
+
+	// 1. Get Tenant for Balance
+	tenant, err := h.Repo.GetTenantByID(tenantID)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	// 2. Get Transactions
+	txs, err := h.Repo.GetCreditTransactions(tenantID, 5) // Last 5
+	if err != nil {
+		http.Error(w, "Failed to fetch transactions", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(CreditsResponse{
+		Balance:      tenant.CreditsBalance,
+		Transactions: txs,
+	})
+}
+
+// Request for adding credits
+type AddCreditsRequest struct {
+	Amount      int    `json:"amount"`
+	Description string `json:"description"`
+}
+
+func (h *AdminHandler) AddCredits(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.Context().Value("tenant_id").(string)
+
+	var req AddCreditsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	err := h.Repo.AddCredits(tenantID, req.Amount, req.Description)
+	if err != nil {
+		http.Error(w, "Failed to add credits", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *AdminHandler) SimulateUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.Context().Value("tenant_id").(string)
+
+	// Deduct 1 credit
+	err := h.Repo.AddCredits(tenantID, -1, "Verification Usage (Simulation)")
+	if err != nil {
+		http.Error(w, "Failed to deduct credit", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ----------------------------------------
+// Admin Review Endpoints
+// ----------------------------------------
+
+func (h *AdminHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value("tenant_id").(string)
+	search := r.URL.Query().Get("search")
+
+	sessions, err := h.Repo.ListSessions(tenantID, 50, search)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to list sessions: %v\n", err)
+		http.Error(w, fmt.Sprintf("Failed to list sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+type SessionReviewResponse struct {
+	Session  *domain.Session         `json:"session"`
+	Images   map[string]string       `json:"images"`   // Deprecated: presigned URLs by key, see Evidence
+	Evidence []service.EvidenceGroup `json:"evidence"` // Artifacts grouped by flow step
+	TenantID string                  `json:"tenant_id"`
+	Review   *domain.ReviewState     `json:"review,omitempty"`
+	Events   []domain.SessionEvent   `json:"events"` // Audit trail, oldest first
+	Steps    []ReviewStep            `json:"steps"`  // Flow steps, e.g. to pick which ones to resubmit
+	// Other sessions of the tenant sharing a document, selfie or identity field
+	Duplicates []domain.DuplicateMatch `json:"duplicates"`
+}
+
+type ReviewStep struct {
+	StepID   string              `json:"step_id"`
+	Type     string              `json:"type"`
+	Label    string              `json:"label"`
+	Strategy domain.StepStrategy `json:"strategy"`
+}
+
+func (h *AdminHandler) GetSessionReview(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(TenantIDKey).(string)
+	session, flow, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+
+	// Artifacts grouped by the step that produced them (and by the resubmission
+	// round that archived them), with presigned GET URLs
+	evidence := service.CollectEvidence(flow, session)
+	images := make(map[string]string)
+	for _, group := range evidence {
+		for i := range group.Items {
+			item := &group.Items[i]
+			if item.Kind != service.EvidenceFile {
+				continue
+			}
+			if url, err := h.Storage.GeneratePresignedGetURL(r.Context(), item.ObjectKey); err == nil {
+				item.URL = url
+				if group.Round == 0 {
+					images[item.Key] = url
+				}
+			} else {
+				log.Printf("ERROR: Failed to sign %s: %v", item.Key, err)
+			}
+			if item.ThumbnailKey != "" {
+				if url, err := h.Storage.GeneratePresignedGetURL(r.Context(), item.ThumbnailKey); err == nil {
+					item.ThumbnailURL = url
+				}
+			}
+		}
+	}
+
+	review, err := h.Repo.GetReviewState(session.Token)
+	if err != nil {
+		log.Printf("ERROR: Failed to load review state: %v", err)
+	}
+	events, err := h.Repo.ListSessionEvents(session.ID.String())
+	if err != nil {
+		log.Printf("ERROR: Failed to load session events: %v", err)
+		events = []domain.SessionEvent{}
+	}
+	duplicates, err := h.Repo.FindDuplicateSessions(session.ID.String(), service.DuplicateImageDistance)
+	if err != nil {
+		log.Printf("ERROR: Failed to load duplicate sessions: %v", err)
+		duplicates = []domain.DuplicateMatch{}
+	}
+
+	resp := SessionReviewResponse{
+		Session:    session,
+		Images:     images,
+		Evidence:   evidence,
+		TenantID:   flow.TenantID.String(),
+		Review:     review,
+		Events:     events,
+		Duplicates: duplicates,
+		Steps:      make([]ReviewStep, 0, len(flow.StepsConfiguration)),
+	}
+	for _, step := range flow.StepsConfiguration {
+		resp.Steps = append(resp.Steps, ReviewStep{StepID: step.StepID, Type: step.Type, Label: service.StepLabel(flow, step), Strategy: step.Strategy})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type ReviewDecisionRequest struct {
+	Status     string `json:"status"`      // approved, rejected
+	ReasonCode string `json:"reason_code"` // From the tenant taxonomy, see /admin/review-reasons
+	Notes      string `json:"notes"`
+	Reason     string `json:"reason"` // Deprecated: free text, stored as notes
+}
+
+type ReviewDecisionResponse struct {
+	Status domain.SessionStatus `json:"status"` // PENDING_CONFIRMATION when a second reviewer must confirm
+}
+
+// readDecision decodes a reviewer decision and checks its reason code against
+// the tenant taxonomy. On failure the error response is already written.
+func (h *AdminHandler) readDecision(w http.ResponseWriter, r *http.Request, tenantID string) (*ReviewDecisionRequest, domain.SessionStatus, bool) {
+	var req ReviewDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	// Validate status
+	status := domain.SessionStatus(strings.ToUpper(req.Status))
+	if status != domain.StatusApproved && status != domain.StatusRejected {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	codes, err := h.Repo.GetTenantReviewReasons(tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load review reasons: %v", err)
+		http.Error(w, "Failed to load review reasons", http.StatusInternalServerError)
+		return nil, "", false
+	}
+	if err := service.CheckReasonCode(service.ReasonCodesFor(codes), req.ReasonCode, status); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+	if req.Notes == "" {
+		req.Notes = req.Reason
+	}
+	return &req, status, true
+}
+
+// DecideSession applies a reviewer decision. Only sessions of the tenant that
+// are awaiting a reviewer can be decided, and not while another reviewer holds
+// them. When the flow review policy requires four eyes the decision is only
+// proposed, and a different reviewer confirms or overturns it with this same
+// endpoint.
+func (h *AdminHandler) DecideSession(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	session, flow, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+	req, status, ok := h.readDecision(w, r, tenantID)
+	if !ok {
+		return
+	}
+
+	from := session.Status
+	event := domain.SessionEvent{
+		FromStatus: from,
+		ActorType:  domain.ActorReviewer,
+		ActorID:    userID.String(),
+		ReasonCode: req.ReasonCode,
+		Notes:      req.Notes,
+	}
+
+	var decided bool
+	var err error
+	switch {
+	case from == domain.StatusPendingConfirmation:
+		review, stateErr := h.Repo.GetReviewState(session.Token)
+		if stateErr != nil {
+			http.Error(w, "Failed to load review state", http.StatusInternalServerError)
+			return
+		}
+		if review.ProposedBy != nil && *review.ProposedBy == userID {
+			http.Error(w, "The decision must be confirmed by a different reviewer", http.StatusForbidden)
+			return
+		}
+		decided, err = h.Repo.ConfirmDecision(session.Token, status, userID.String())
+		session.Status = status
+	case from != domain.StatusReview:
+		http.Error(w, "Session is not awaiting review", http.StatusConflict)
+		return
+	case service.RequiresSecondReview(flow.ReviewPolicy, status, session.Results):
+		decided, err = h.Repo.ProposeDecision(session.Token, status, userID.String())
+		session.Status = domain.StatusPendingConfirmation
+		event.ProposedStatus = status
+	default:
+		decided, err = h.Repo.DecideReview(session.Token, status, userID.String())
+		session.Status = status
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to decide session: %v", err)
+		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+		return
+	}
+	if !decided {
+		http.Error(w, "Claim the session before deciding, your lock is missing or expired", http.StatusConflict)
+		return
+	}
+	recordTransition(h.Repo, session, event)
+	publishSession(h.Stream, flow.TenantID, session, service.StreamSessionStatusChanged, from)
+
+	// Notify Tenant once the decision is final
+	if session.Status != domain.StatusPendingConfirmation {
+		if tenant, err := h.Repo.GetTenantByID(flow.TenantID.String()); err == nil {
+			notifySession(h.Repo, h.Webhooks, tenant, session)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReviewDecisionResponse{Status: session.Status})
+}
+
+// OverrideSession changes an APPROVED or REJECTED decision. Only the roles of the
+// flow review policy can do it, and notes explaining why are required.
+func (h *AdminHandler) OverrideSession(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	role, _ := r.Context().Value(RoleKey).(string)
+	session, flow, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if !service.CanOverride(flow.ReviewPolicy, role) {
+		http.Error(w, "Your role can't override decisions", http.StatusForbidden)
+		return
+	}
+	req, status, ok := h.readDecision(w, r, tenantID)
+	if !ok {
+		return
+	}
+
+	from := session.Status
+	if from != domain.StatusApproved && from != domain.StatusRejected {
+		http.Error(w, "Only approved or rejected sessions can be overridden", http.StatusConflict)
+		return
+	}
+	if status == from {
+		http.Error(w, "Session already has that status", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Notes) == "" {
+		http.Error(w, "Notes are required to override a decision", http.StatusBadRequest)
+		return
+	}
+
+	overridden, err := h.Repo.OverrideDecision(session.Token, from, status, userID.String())
+	if err != nil {
+		log.Printf("ERROR: Failed to override session: %v", err)
+		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+		return
+	}
+	if !overridden {
+		http.Error(w, "Session status changed, reload it", http.StatusConflict)
+		return
+	}
+	session.Status = status
+	recordTransition(h.Repo, session, domain.SessionEvent{
+		FromStatus: from,
+		ActorType:  domain.ActorReviewer,
+		ActorID:    userID.String(),
+		ReasonCode: req.ReasonCode,
+		Notes:      req.Notes,
+	})
+	publishSession(h.Stream, flow.TenantID, session, service.StreamSessionStatusChanged, from)
+
+	if tenant, err := h.Repo.GetTenantByID(flow.TenantID.String()); err == nil {
+		notifySession(h.Repo, h.Webhooks, tenant, session)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReviewDecisionResponse{Status: session.Status})
+}
+
+const (
+	defaultResubmissionExpiry = 2 * time.Hour
+	maxResubmissionExpiry     = 72 * time.Hour
+)
+
+type ResubmissionRequest struct {
+	StepIDs    []string `json:"step_ids"`
+	ReasonCode string   `json:"reason_code"` // Must apply to RESUBMIT
+	Notes      string   `json:"notes"`       // Shown to the tenant, not to the user
+	ExpiresIn  int      `json:"expires_in"`  // Seconds, 2h by default and 72h at most
+}
+
+type ResubmissionResponse struct {
+	Status    domain.SessionStatus `json:"status"`
+	URL       string               `json:"url"` // New link, the previous one no longer works
+	StepIDs   []string             `json:"step_ids"`
+	ExpiresAt time.Time            `json:"expires_at"`
+}
+
+// RequestResubmission sends a session awaiting review back to the user to redo
+// some steps. The session gets a new short lived token, whose link is returned
+// and sent to the tenant webhook. What was collected and worked out so far is
+// archived in results["previous_submissions"] for comparison.
+func (h *AdminHandler) RequestResubmission(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	session, flow, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if session.Status != domain.StatusReview {
+		http.Error(w, "Session is not awaiting review", http.StatusConflict)
+		return
+	}
+
+	var req ResubmissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	steps, err := service.ResubmissionSteps(flow, req.StepIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	codes, err := h.Repo.GetTenantReviewReasons(tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load review reasons: %v", err)
+		http.Error(w, "Failed to load review reasons", http.StatusInternalServerError)
+		return
+	}
+	if err := service.CheckReasonCode(service.ReasonCodesFor(codes), req.ReasonCode, domain.DecisionResubmit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expiresIn := time.Duration(req.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = defaultResubmissionExpiry
+	}
+	if expiresIn > maxResubmissionExpiry {
+		http.Error(w, "expires_in can't exceed 72 hours", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := h.Repo.GetTenantByID(tenantID)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusInternalServerError)
+		return
+	}
+
+	from := session.Status
+	now := time.Now()
+	session.ExpiresAt = now.Add(expiresIn)
+	service.ReopenSession(flow, session, service.Resubmission{
+		StepIDs:     steps,
+		ReasonCode:  req.ReasonCode,
+		Notes:       req.Notes,
+		RequestedBy: userID.String(),
+		RequestedAt: now,
+		ExpiresAt:   session.ExpiresAt,
+	})
+
+	oldToken := session.Token
+	session.Token = uuid.New().String()
+	reopened, err := h.Repo.ReopenSession(oldToken, session.Token, session, userID.String())
+	if err != nil {
+		log.Printf("ERROR: Failed to reopen session: %v", err)
+		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+		return
+	}
+	if !reopened {
+		http.Error(w, "Claim the session before requesting a resubmission, your lock is missing or expired", http.StatusConflict)
+		return
+	}
+	notes := "Resubmit: " + strings.Join(steps, ", ")
+	if req.Notes != "" {
+		notes += ". " + req.Notes
+	}
+	recordTransition(h.Repo, session, domain.SessionEvent{
+		FromStatus: from,
+		ActorType:  domain.ActorReviewer,
+		ActorID:    userID.String(),
+		ReasonCode: req.ReasonCode,
+		Notes:      notes,
+	})
+	publishSession(h.Stream, tenant.ID, session, service.StreamSessionStatusChanged, from)
+
+	notice := service.ResubmissionNotice{
+		URL:       service.BuildFlowStartURL(config.GetSecureFlowBaseURL(), tenant.CustomDomain, session.Token),
+		StepIDs:   steps,
+		ExpiresAt: session.ExpiresAt,
+	}
+	notifyResubmission(h.Repo, h.Webhooks, tenant, session, notice)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ResubmissionResponse{
+		Status:    session.Status,
+		URL:       notice.URL,
+		StepIDs:   notice.StepIDs,
+		ExpiresAt: notice.ExpiresAt,
+	})
+}
+
+type ReviewReasonsResponse struct {
+	ReasonCodes domain.ReasonCodes `json:"reason_codes"`
+	IsDefault   bool               `json:"is_default"` // The tenant hasn't configured its own taxonomy
+}
+
+func (h *AdminHandler) GetReviewReasons(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(TenantIDKey).(string)
+	codes, err := h.Repo.GetTenantReviewReasons(tenantID)
+	if err != nil {
+		http.Error(w, "Failed to load review reasons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReviewReasonsResponse{ReasonCodes: service.ReasonCodesFor(codes), IsDefault: len(codes) == 0})
+}
+
+// UpdateReviewReasons replaces the tenant taxonomy; an empty list restores the default
+func (h *AdminHandler) UpdateReviewReasons(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(TenantIDKey).(string)
+
+	var req ReviewReasonsResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateReasonCodes(req.ReasonCodes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.UpdateTenantReviewReasons(tenantID, req.ReasonCodes); err != nil {
+		log.Printf("ERROR: Failed to update review reasons: %v", err)
+		http.Error(w, "Failed to update review reasons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReviewReasonsResponse{ReasonCodes: service.ReasonCodesFor(req.ReasonCodes), IsDefault: len(req.ReasonCodes) == 0})
+}
+
+type RateLimitsResponse struct {
+	RateLimits domain.RateLimitPolicy `json:"rate_limits"` // Configured policy, zero means default
+	Effective  domain.RateLimitPolicy `json:"effective"`   // Limits actually applied
+}
+
+func (h *AdminHandler) GetRateLimits(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(TenantIDKey).(string)
+	tenant, err := h.Repo.GetTenantByID(tenantID)
+	if err != nil {
+		http.Error(w, "Failed to load rate limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RateLimitsResponse{RateLimits: tenant.RateLimits, Effective: service.RateLimitsFor(tenant.RateLimits)})
+}
+
+// UpdateRateLimits replaces the tenant policy; zero values restore the defaults
+func (h *AdminHandler) UpdateRateLimits(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(TenantIDKey).(string)
+
+	var req domain.RateLimitPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateRateLimits(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.UpdateTenantRateLimits(tenantID, req); err != nil {
+		log.Printf("ERROR: Failed to update rate limits: %v", err)
+		http.Error(w, "Failed to update rate limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RateLimitsResponse{RateLimits: req, Effective: service.RateLimitsFor(req)})
+}
+
+// ----------------------------------------
+// Flow Management Endpoints
+// ----------------------------------------
+
+func (h *AdminHandler) ListFlows(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flows, err := h.Repo.ListFlows(tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to list flows: %v", err)
+		http.Error(w, "Failed to list flows", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flows)
+}
+
+func (h *AdminHandler) GetFlow(w http.ResponseWriter, r *http.Request) {
+	flowID := r.URL.Query().Get("id")
+	if flowID == "" {
+		http.Error(w, "Missing flow ID", http.StatusBadRequest)
+		return
+	}
+
+	flow, err := h.Repo.GetFlowByID(flowID)
+	if err != nil {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+
+	// Verify Tenant Access
+	tenantID, _ := r.Context().Value("tenant_id").(string)
+	if flow.TenantID.String() != tenantID {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flow)
+}
+
+type CreateFlowRequest struct {
+	Name               string                `json:"name"`
+	Description        string                `json:"description"`
+	StepsConfiguration domain.StepsConfig    `json:"steps_configuration"`
+	DefaultLocale      string                `json:"default_locale"`
+	Translations       domain.Translations   `json:"translations"`
+	DecisionPolicy     domain.DecisionPolicy `json:"decision_policy"`
+	ReviewPolicy       domain.ReviewPolicy   `json:"review_policy"`
+}
+
+// UpdateFlowRequest is a CreateFlowRequest whose locale settings and policies
+// are kept when the request leaves them out
+type UpdateFlowRequest struct {
+	CreateFlowRequest
+	DefaultLocale  *string                `json:"default_locale"`
+	Translations   *domain.Translations   `json:"translations"`
+	DecisionPolicy *domain.DecisionPolicy `json:"decision_policy"`
+	ReviewPolicy   *domain.ReviewPolicy   `json:"review_policy"`
+}
+
+type FlowValidationResponse struct {
+	Valid  bool                `json:"valid"`
+	Issues []service.FlowIssue `json:"issues"`
+}
+
+// validateFlow runs the flow validator with the tenant's templates. If the flow has
+// blocking errors the 400 response is already written and false is returned.
+func (h *AdminHandler) validateFlow(w http.ResponseWriter, tenantID string, flow *domain.Flow) ([]service.FlowIssue, bool) {
+	templates := make(map[uuid.UUID]domain.StepTemplate)
+	list, err := h.Repo.ListStepTemplates(tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load templates for validation: %v", err)
+		http.Error(w, "Failed to validate flow", http.StatusInternalServerError)
+		return nil, false
+	}
+	for _, t := range list {
+		templates[t.ID] = t
+	}
+
+	issues := service.ValidateFlow(flow, templates)
+	if service.HasErrors(issues) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(FlowValidationResponse{Valid: false, Issues: issues})
+		return issues, false
+	}
+	return issues, true
+}
+
+// normalizeFlowLocales applies the default locale and canonical locale codes
+func normalizeFlowLocales(flow *domain.Flow) {
+	if flow.DefaultLocale == "" {
+		flow.DefaultLocale = service.FallbackLocale
+	}
+	if l, err := service.NormalizeLocale(flow.DefaultLocale); err == nil {
+		flow.DefaultLocale = l
+	}
+	normalized := domain.Translations{}
+	for locale, table := range flow.Translations {
+		if l, err := service.NormalizeLocale(locale); err == nil {
+			locale = l
+		}
+		normalized[locale] = table
+	}
+	flow.Translations = normalized
+}
+
+// ValidateFlow reports structural errors and missing translations without saving
+func (h *AdminHandler) ValidateFlow(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	flow := &domain.Flow{
+		Name:               req.Name,
+		StepsConfiguration: req.StepsConfiguration,
+		DefaultLocale:      req.DefaultLocale,
+		Translations:       req.Translations,
+		DecisionPolicy:     req.DecisionPolicy,
+		ReviewPolicy:       req.ReviewPolicy,
+	}
+	normalizeFlowLocales(flow)
+
+	issues, ok := h.validateFlow(w, tenantID, flow)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FlowValidationResponse{Valid: true, Issues: issues})
+}
+
+func (h *AdminHandler) CreateFlow(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	tID, err := uuid.Parse(tenantID)
+	if err != nil {
+		http.Error(w, "Invalid Tenant ID", http.StatusInternalServerError)
+		return
+	}
+
+	flow := &domain.Flow{
+		ID:                 uuid.New(),
+		TenantID:           tID,
+		Name:               req.Name,
+		Description:        req.Description,
+		StepsConfiguration: req.StepsConfiguration,
+		DefaultLocale:      req.DefaultLocale,
+		Translations:       req.Translations,
+		DecisionPolicy:     req.DecisionPolicy,
+		ReviewPolicy:       req.ReviewPolicy,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+	normalizeFlowLocales(flow)
+
+	if _, ok := h.validateFlow(w, tenantID, flow); !ok {
+		return
+	}
+
+	if err := h.Repo.CreateFlow(flow); err != nil {
+		log.Printf("ERROR: Failed to create flow: %v", err)
+		http.Error(w, "Failed to create flow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(flow)
+}
+
+func (h *AdminHandler) UpdateFlow(w http.ResponseWriter, r *http.Request) {
+	flowIDStr := r.URL.Query().Get("id")
+	if flowIDStr == "" {
+		http.Error(w, "Missing flow ID", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value("tenant_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Check existence and ownership
+	existingFlow, err := h.Repo.GetFlowByID(flowIDStr)
+	if err != nil {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+
+	if existingFlow.TenantID.String() != tenantID {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	existingFlow.Name = req.Name
+	existingFlow.Description = req.Description
+	existingFlow.StepsConfiguration = req.StepsConfiguration
+	if req.DefaultLocale != nil {
+		existingFlow.DefaultLocale = *req.DefaultLocale
+	}
+	if req.Translations != nil {
+		existingFlow.Translations = *req.Translations
+	}
+	if req.DecisionPolicy != nil {
+		existingFlow.DecisionPolicy = *req.DecisionPolicy
+	}
+	if req.ReviewPolicy != nil {
+		existingFlow.ReviewPolicy = *req.ReviewPolicy
+	}
+	existingFlow.UpdatedAt = time.Now()
+	normalizeFlowLocales(existingFlow)
+
+	if _, ok := h.validateFlow(w, tenantID, existingFlow); !ok {
+		return
+	}
+
+	if err := h.Repo.UpdateFlow(existingFlow); err != nil {
+		log.Printf("ERROR: Failed to update flow: %v", err)
+		http.Error(w, "Failed to update flow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(existingFlow)
+}
+
+type SimulatePolicyRequest struct {
+	DecisionPolicy *domain.DecisionPolicy `json:"decision_policy"` // Defaults to the saved policy of the flow
+	Limit          int                    `json:"limit"`
+}
+
+type SimulatedDecision struct {
+	Token         string                   `json:"token"`
+	UserReference string                   `json:"user_reference"`
+	CreatedAt     time.Time                `json:"created_at"`
+	CurrentStatus domain.SessionStatus     `json:"current_status"`
+	Decision      *service.DecisionOutcome `json:"decision"`
+	StatusChanged bool                     `json:"status_changed"`
+}
+
+type SimulatePolicyResponse struct {
+	Evaluated int                          `json:"evaluated"`
+	Changed   int                          `json:"changed"`
+	Summary   map[domain.SessionStatus]int `json:"summary"` // Simulated status -> sessions
+	Results   []SimulatedDecision          `json:"results"`
+}
+
+// SimulatePolicy evaluates a decision policy against the latest finished
+// sessions of a flow without changing them, to try rules before saving them.
+func (h *AdminHandler) SimulatePolicy(w http.ResponseWriter, r *http.Request) {
+	flowID := r.URL.Query().Get("id")
+	if flowID == "" {
+		http.Error(w, "Missing flow ID", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, _ := r.Context().Value("tenant_id").(string)
+	flow, err := h.Repo.GetFlowByID(flowID)
+	if err != nil {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+	if flow.TenantID.String() != tenantID {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SimulatePolicyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	policy := service.PolicyFor(flow)
+	if req.DecisionPolicy != nil {
+		policy = *req.DecisionPolicy
+	}
+	if issues := service.ValidateDecisionPolicy(policy); service.HasErrors(issues) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(FlowValidationResponse{Valid: false, Issues: issues})
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 500 {
+		req.Limit = 100
+	}
+
+	sessions, err := h.Repo.ListFinishedSessions(flowID, req.Limit)
+	if err != nil {
+		log.Printf("ERROR: Failed to load sessions for simulation: %v", err)
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := SimulatePolicyResponse{Summary: map[domain.SessionStatus]int{}, Results: []SimulatedDecision{}}
+	for i := range sessions {
+		session := &sessions[i]
+		outcome, err := service.EvaluatePolicy(policy, session)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result := SimulatedDecision{
+			Token:         session.Token,
+			UserReference: session.UserReference,
+			CreatedAt:     session.CreatedAt,
+			CurrentStatus: session.Status,
+			Decision:      outcome,
+			StatusChanged: outcome.Status != session.Status,
+		}
+		resp.Evaluated++
+		resp.Summary[outcome.Status]++
+		if result.StatusChanged {
+			resp.Changed++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *AdminHandler) DeleteFlow(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/config"
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/aoricaan/idv-core/internal/service"
+)
+
+// DeviceFingerprintHeader carries the fingerprint computed by the Secure Flow
+const DeviceFingerprintHeader = "X-Device-Fingerprint"
+
+// SessionSecretHeader carries the secret of the device the session is bound to
+const SessionSecretHeader = "X-Session-Secret"
+
+// Secure Flow endpoints recorded in session_accesses
+const (
+	accessGetSession = "get_session"
+	accessSubmitStep = "submit_step"
+	accessUploadURL  = "upload_url"
+	accessHandoff    = "handoff" // get_session redeeming a handoff code
+)
+
+// recordAccess logs who is calling a Secure Flow endpoint of the session.
+// Failing to record it is logged, the request goes on.
+func (h *SessionHandler) recordAccess(r *http.Request, session *domain.Session, endpoint string) {
+	access := &domain.SessionAccess{
+		SessionID:      session.ID,
+		Endpoint:       endpoint,
+		IP:             clientIP(r),
+		UserAgent:      truncate(r.UserAgent(), 512),
+		AcceptLanguage: truncate(r.Header.Get("Accept-Language"), 255),
+		DeviceID:       truncate(r.Header.Get(DeviceFingerprintHeader), 128),
+		Country:        clientCountry(r),
+	}
+	if err := h.Repo.AddSessionAccess(access); err != nil {
+		log.Printf("ERROR: Failed to record access to session %s: %v", session.ID, err)
+	}
+}
+
+// openSession authorizes the device calling GetSession. The first device to
+// open the session is bound to it and gets a secret; a handoff code moves the
+// binding to the calling device. The new secret is returned, if any. On failure
+// the error response is already written.
+func (h *SessionHandler) openSession(w http.ResponseWriter, r *http.Request, tenant *domain.Tenant, session *domain.Session) (string, bool) {
+	code := r.URL.Query().Get("handoff")
+	if code == "" && session.DeviceSecretHash != "" {
+		return "", h.authorizeDevice(w, r, session)
+	}
+
+	secret, hash, err := service.NewDeviceSecret()
+	if err != nil {
+		http.Error(w, "Failed to bind session", http.StatusInternalServerError)
+		return "", false
+	}
+	var bound bool
+	if code != "" {
+		bound, err = h.Repo.RedeemHandoff(session.ID.String(), service.HashDeviceSecret(code), hash)
+	} else {
+		bound, err = h.Repo.BindSessionDevice(session.ID.String(), hash)
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to bind session %s: %v", session.ID, err)
+		http.Error(w, "Failed to bind session", http.StatusInternalServerError)
+		return "", false
+	}
+	if !bound {
+		message := "This verification is open on another device"
+		if code != "" {
+			message = "This link to continue on another device is invalid or expired"
+		}
+		h.writeDeviceDenied(w, message)
+		return "", false
+	}
+	if code != "" {
+		session.WatcherSecretHash = session.DeviceSecretHash
+		recordDeviceEvent(h.Repo, session, domain.DeviceHandoffCompleted, truncate(r.UserAgent(), 200))
+		publishSession(h.Stream, tenant.ID, session, service.StreamDeviceChanged, session.Status)
+	}
+	session.DeviceSecretHash = hash
+	return secret, true
+}
+
+// HandoffResponse is the one-time link that moves a session to another device
+type HandoffResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateHandoff lets the device the session is bound to hand it over to another
+// one ("continue on another device", e.g. a QR code shown on desktop for the
+// phone). The link works once, within HandoffTTL. When it is opened the current
+// device loses access and can only follow the progress (GetSessionProgress).
+func (h *SessionHandler) CreateHandoff(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.Repo.GetSessionByToken(token)
+	if err != nil {
+		http.Error(w, "Invalid session", http.StatusNotFound)
+		return
+	}
+	if service.ResubmissionExpired(session) {
+		http.Error(w, "Resubmission link expired", http.StatusGone)
+		return
+	}
+
+	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
+	if err != nil {
+		http.Error(w, "Flow configuration not found", http.StatusInternalServerError)
+		return
+	}
+
+	tenant, err := h.Repo.GetTenantByID(flow.TenantID.String())
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.verifyFlowHost(r, tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !h.allowPublicRequest(w, r, tenant) {
+		return
+	}
+	if !h.authorizeDevice(w, r, session) {
+		return
+	}
+	if session.Status.IsFinal() {
+		http.Error(w, "Session already finished", http.StatusConflict)
+		return
+	}
+
+	code, err := service.NewSigningSecret()
+	if err != nil {
+		http.Error(w, "Failed to create handoff", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(service.HandoffTTL)
+	if err := h.Repo.CreateHandoff(session.ID.String(), service.HashDeviceSecret(code), expiresAt); err != nil {
+		log.Printf("ERROR: Failed to create handoff for session %s: %v", session.ID, err)
+		http.Error(w, "Failed to create handoff", http.StatusInternalServerError)
+		return
+	}
+	recordDeviceEvent(h.Repo, session, domain.DeviceHandoffCreated, "")
+	publishSession(h.Stream, tenant.ID, session, service.StreamHandoffCreated, session.Status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(HandoffResponse{
+		URL:       service.BuildHandoffURL(config.GetSecureFlowBaseURL(), tenant.CustomDomain, session.Token, code),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// SessionProgressResponse lets the device that handed a session over follow it
+type SessionProgressResponse struct {
+	Status         domain.SessionStatus `json:"status"`
+	Finished       bool                 `json:"finished"` // Nothing left to do in the flow
+	CompletedSteps int                  `json:"completed_steps"`
+	TotalSteps     int                  `json:"total_steps"`
+	OnThisDevice   bool                 `json:"on_this_device"`         // False once another device took over
+	RedirectURL    string               `json:"redirect_url,omitempty"` // Set when the flow finished
+}
+
+// GetSessionProgress lets the device that created a handoff notice when the
+// other device opens the link and completes steps. The bound device may ask as
+// well. StreamSession pushes the same response.
+func (h *SessionHandler) GetSessionProgress(w http.ResponseWriter, r *http.Request) {
+	session, flow, tenant, ok := h.loadWatchedSession(w, r)
+	if !ok {
+		return
+	}
+	progress, ok := h.sessionProgress(r, flow, tenant, session)
+	if !ok {
+		h.writeDeviceDenied(w, "This verification is open on another device")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// loadWatchedSession loads the session of the token with its flow and tenant
+// for the progress endpoints. On failure the error response is already written.
+func (h *SessionHandler) loadWatchedSession(w http.ResponseWriter, r *http.Request) (*domain.Session, *domain.Flow, *domain.Tenant, bool) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+
+	session, err := h.Repo.GetSessionByToken(token)
+	if err != nil {
+		http.Error(w, "Invalid session", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+
+	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
+	if err != nil {
+		http.Error(w, "Flow configuration not found", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+
+	tenant, err := h.Repo.GetTenantByID(flow.TenantID.String())
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+
+	if err := h.verifyFlowHost(r, tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, nil, nil, false
+	}
+	if !h.allowPublicRequest(w, r, tenant) {
+		return nil, nil, nil, false
+	}
+	// Not recorded in session_accesses: polling would drown the device signals
+	return session, flow, tenant, true
+}
+
+// sessionProgress describes the session to the bound device or to the one that
+// handed it over, false for any other device
+func (h *SessionHandler) sessionProgress(r *http.Request, flow *domain.Flow, tenant *domain.Tenant, session *domain.Session) (SessionProgressResponse, bool) {
+	secret := r.Header.Get(SessionSecretHeader)
+	onThisDevice := service.DeviceSecretMatches(session.DeviceSecretHash, secret)
+	if !onThisDevice && !service.DeviceSecretMatches(session.WatcherSecretHash, secret) {
+		return SessionProgressResponse{}, false
+	}
+
+	total := len(flow.StepsConfiguration)
+	return SessionProgressResponse{
+		Status:         session.Status,
+		Finished:       session.Status.IsFinal(),
+		CompletedSteps: min(session.CurrentStepIndex, total),
+		TotalSteps:     total,
+		OnThisDevice:   onThisDevice,
+		RedirectURL:    h.returnRedirect(session, tenant),
+	}, true
+}
+
+// authorizeDevice requires the secret of the device the session is bound to
+func (h *SessionHandler) authorizeDevice(w http.ResponseWriter, r *http.Request, session *domain.Session) bool {
+	if service.DeviceSecretMatches(session.DeviceSecretHash, r.Header.Get(SessionSecretHeader)) {
+		return true
+	}
+	h.writeDeviceDenied(w, "This verification is open on another device")
+	return false
+}
+
+func (h *SessionHandler) writeDeviceDenied(w http.ResponseWriter, message string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Error(w, message, http.StatusForbidden)
+}
+
+// runDeviceSignals summarizes the accesses of the session into
+// results["device"] and raises the device and network risk signals
+func (h *SessionHandler) runDeviceSignals(session *domain.Session) {
+	accesses, err := h.Repo.ListSessionAccesses(session.ID.String())
+	if err != nil {
+		log.Printf("ERROR: Failed to load accesses of session %s: %v", session.ID, err)
+		return
+	}
+	summary := service.SummarizeAccesses(accesses)
+	session.Results["device"] = summary
+	service.AddNewRiskSignals(session.Results, service.DeviceSignals(summary, session.Results, session.CollectedData)...)
+}
+
+// clientCountry is the alpha-2 country the proxy geolocated the client in
+func clientCountry(r *http.Request) string {
+	header := config.GetCountryHeader()
+	if header == "" || !config.TrustProxyHeaders() {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+	if len(country) != 2 || country == "XX" {
+		return ""
+	}
+	return country
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
@@ -10,7 +10,11 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const TenantIDKey = "tenant_id"
+const (
+	TenantIDKey = "tenant_id"
+	UserIDKey   = "user_id"
+	RoleKey     = "role"
+)
 
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -55,8 +59,14 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 
-			// Inject TenantID into Context
+			// Inject TenantID and the user into Context
 			ctx := context.WithValue(r.Context(), TenantIDKey, tenantID)
+			if userID, ok := claims["sub"].(string); ok {
+				ctx = context.WithValue(ctx, UserIDKey, userID)
+			}
+			if role, ok := claims["role"].(string); ok {
+				ctx = context.WithValue(ctx, RoleKey, role)
+			}
 			next(w, r.WithContext(ctx))
 		} else {
 			http.Error(w, "Invalid Token Claims", http.StatusUnauthorized)
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/config"
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/aoricaan/idv-core/internal/service"
+)
+
+// clientIP is the end-user address: the first X-Forwarded-For hop when the
+// engine runs behind a trusted proxy, otherwise the connection peer
+func clientIP(r *http.Request) string {
+	if config.TrustProxyHeaders() {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allowRate takes a token from the bucket of key. When the limit is exceeded
+// it writes a 429 with Retry-After and returns false.
+func (h *SessionHandler) allowRate(w http.ResponseWriter, r *http.Request, key string, limit service.RateLimit) bool {
+	if h.Limiter == nil {
+		return true
+	}
+	allowed, wait := h.Limiter.Allow(r.Context(), key, limit)
+	if allowed {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	return false
+}
+
+// allowSessionCreation applies the per API key and per user_reference limits
+// of InitSession, before any credit is spent. Sessions without a
+// user_reference only count against the API key.
+func (h *SessionHandler) allowSessionCreation(w http.ResponseWriter, r *http.Request, tenant *domain.Tenant, userReference string) bool {
+	policy := service.RateLimitsFor(tenant.RateLimits)
+	if !h.allowRate(w, r, "key:"+tenant.ID.String(), service.RateLimit{Capacity: policy.SessionsPerMinute, Period: time.Minute}) {
+		return false
+	}
+	if userReference == "" {
+		return true
+	}
+	ref := sha256.Sum256([]byte(userReference))
+	return h.allowRate(w, r, "user:"+tenant.ID.String()+":"+hex.EncodeToString(ref[:]), service.RateLimit{Capacity: policy.SessionsPerUserPerDay, Period: 24 * time.Hour})
+}
+
+// allowPublicRequest applies the per IP limit of the Secure Flow endpoints
+func (h *SessionHandler) allowPublicRequest(w http.ResponseWriter, r *http.Request, tenant *domain.Tenant) bool {
+	policy := service.RateLimitsFor(tenant.RateLimits)
+	return h.allowRate(w, r, "ip:"+tenant.ID.String()+":"+clientIP(r), service.RateLimit{Capacity: policy.PublicRequestsPerMinute, Period: time.Minute})
+}
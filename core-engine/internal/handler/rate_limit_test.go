@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/aoricaan/idv-core/internal/service"
+	"github.com/google/uuid"
+)
+
+func TestAllowRateRetryAfter(t *testing.T) {
+	tests := []struct {
+		limit service.RateLimit
+		want  string
+	}{
+		{service.RateLimit{Capacity: 1, Period: time.Minute}, "60"},
+		{service.RateLimit{Capacity: 1, Period: 90 * time.Second}, "90"},
+		{service.RateLimit{Capacity: 120, Period: time.Minute}, "1"}, // Half a second rounds up to 1
+	}
+	for _, tt := range tests {
+		h := &SessionHandler{Limiter: service.NewRateLimiter(nil)}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for i := 0; i < tt.limit.Capacity; i++ {
+			if !h.allowRate(httptest.NewRecorder(), r, "k", tt.limit) {
+				t.Fatalf("%+v: request %d was refused", tt.limit, i+1)
+			}
+		}
+		w := httptest.NewRecorder()
+		if h.allowRate(w, r, "k", tt.limit) {
+			t.Fatalf("%+v: request over the capacity was allowed", tt.limit)
+		}
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != tt.want {
+			t.Errorf("%+v: %d Retry-After %q, want 429 Retry-After %q", tt.limit, w.Code, w.Header().Get("Retry-After"), tt.want)
+		}
+	}
+}
+
+func TestAllowSessionCreationPerUserReference(t *testing.T) {
+	h := &SessionHandler{Limiter: service.NewRateLimiter(nil)}
+	tenant := &domain.Tenant{ID: uuid.New()}
+	r := httptest.NewRequest(http.MethodPost, "/sessions", nil)
+
+	// Sessions without a user_reference only count against the API key
+	for i := 0; i < service.DefaultSessionsPerUserPerDay+1; i++ {
+		if !h.allowSessionCreation(httptest.NewRecorder(), r, tenant, "") {
+			t.Fatalf("session %d without a user_reference was refused", i+1)
+		}
+	}
+	for i := 0; i < service.DefaultSessionsPerUserPerDay; i++ {
+		if !h.allowSessionCreation(httptest.NewRecorder(), r, tenant, "user-1") {
+			t.Fatalf("session %d of user-1 was refused", i+1)
+		}
+	}
+	w := httptest.NewRecorder()
+	if h.allowSessionCreation(w, r, tenant, "user-1") || w.Code != http.StatusTooManyRequests {
+		t.Errorf("session over the daily limit of user-1 = %d, want 429", w.Code)
+	}
+	if !h.allowSessionCreation(httptest.NewRecorder(), r, tenant, "user-2") {
+		t.Error("user-2 shares the bucket of user-1")
+	}
+}
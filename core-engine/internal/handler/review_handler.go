@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ----------------------------------------
+// Manual Review Queue
+// ----------------------------------------
+
+const defaultReviewLock = 15 * time.Minute
+
+// ReviewQueueEntry is a queued session with its SLA position
+type ReviewQueueEntry struct {
+	domain.ReviewQueueItem
+	AgeSeconds  int64     `json:"age_seconds"`
+	SLADueAt    time.Time `json:"sla_due_at"`
+	SLABreached bool      `json:"sla_breached"`
+	Locked      bool      `json:"locked"` // Claimed by a reviewer and not expired
+}
+
+// reviewer returns the tenant and user of an admin request. On failure the
+// error response is already written.
+func reviewer(w http.ResponseWriter, r *http.Request) (string, uuid.UUID, bool) {
+	tenantID, _ := r.Context().Value(TenantIDKey).(string)
+	userIDStr, _ := r.Context().Value(UserIDKey).(string)
+	userID, err := uuid.Parse(userIDStr)
+	if tenantID == "" || err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", uuid.Nil, false
+	}
+	return tenantID, userID, true
+}
+
+// tenantSession loads the session of the ?token= parameter if it belongs to the
+// tenant. Sessions of other tenants are reported as not found.
+func (h *AdminHandler) tenantSession(w http.ResponseWriter, r *http.Request, tenantID string) (*domain.Session, *domain.Flow, bool) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	session, err := h.Repo.GetSessionByToken(token)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
+	if err != nil || flow.TenantID.String() != tenantID {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return session, flow, true
+}
+
+func (h *AdminHandler) reviewLock() time.Duration {
+	if h.ReviewLock <= 0 {
+		return defaultReviewLock
+	}
+	return h.ReviewLock
+}
+
+// ListReviewQueue returns the sessions awaiting review, oldest first.
+// ?assigned_to=me|unassigned|<user id> filters by reviewer.
+func (h *AdminHandler) ListReviewQueue(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+
+	assignedTo := r.URL.Query().Get("assigned_to")
+	switch assignedTo {
+	case "", "unassigned":
+	case "me":
+		assignedTo = userID.String()
+	default:
+		if _, err := uuid.Parse(assignedTo); err != nil {
+			http.Error(w, "Invalid assigned_to", http.StatusBadRequest)
+			return
+		}
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	items, err := h.Repo.ListReviewQueue(tenantID, assignedTo, limit)
+	if err != nil {
+		log.Printf("ERROR: Failed to list review queue: %v", err)
+		http.Error(w, "Failed to list review queue", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	entries := make([]ReviewQueueEntry, 0, len(items))
+	for _, item := range items {
+		entry := ReviewQueueEntry{ReviewQueueItem: item}
+		if item.ReviewStartedAt != nil {
+			entry.AgeSeconds = int64(now.Sub(*item.ReviewStartedAt).Seconds())
+			if h.ReviewSLA > 0 {
+				entry.SLADueAt = item.ReviewStartedAt.Add(h.ReviewSLA)
+				entry.SLABreached = now.After(entry.SLADueAt)
+			}
+		}
+		entry.Locked = item.LockedBy != nil && item.LockedUntil != nil && item.LockedUntil.After(now)
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ClaimSession locks a session to the reviewer for the lock timeout. Claiming a
+// session you already hold extends the lock.
+func (h *AdminHandler) ClaimSession(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	session, _, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if session.Status != domain.StatusReview && session.Status != domain.StatusPendingConfirmation {
+		http.Error(w, "Session is not awaiting review", http.StatusConflict)
+		return
+	}
+
+	claimed, err := h.Repo.ClaimSession(session.Token, userID.String(), time.Now().Add(h.reviewLock()))
+	if err != nil {
+		log.Printf("ERROR: Failed to claim session: %v", err)
+		http.Error(w, "Failed to claim session", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, "Session is being reviewed by someone else", http.StatusConflict)
+		return
+	}
+	h.writeReviewState(w, session.Token)
+}
+
+// ReleaseSession gives up the reviewer's lock without deciding
+func (h *AdminHandler) ReleaseSession(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	session, _, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+
+	released, err := h.Repo.ReleaseSession(session.Token, userID.String())
+	if err != nil {
+		log.Printf("ERROR: Failed to release session: %v", err)
+		http.Error(w, "Failed to release session", http.StatusInternalServerError)
+		return
+	}
+	if !released {
+		http.Error(w, "Session is not claimed by you", http.StatusConflict)
+		return
+	}
+	h.writeReviewState(w, session.Token)
+}
+
+type AssignSessionRequest struct {
+	UserID string `json:"user_id"` // Empty unassigns
+}
+
+// AssignSession makes a tenant user responsible for reviewing a session
+func (h *AdminHandler) AssignSession(w http.ResponseWriter, r *http.Request) {
+	tenantID, _, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	session, _, ok := h.tenantSession(w, r, tenantID)
+	if !ok {
+		return
+	}
+
+	var req AssignSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var assignee *string
+	if req.UserID != "" {
+		user, err := h.Repo.GetTenantUserByID(req.UserID)
+		if err != nil || user.TenantID.String() != tenantID {
+			http.Error(w, "Reviewer not found", http.StatusBadRequest)
+			return
+		}
+		id := user.ID.String()
+		assignee = &id
+	}
+
+	assigned, err := h.Repo.AssignSession(session.Token, assignee)
+	if err != nil {
+		log.Printf("ERROR: Failed to assign session: %v", err)
+		http.Error(w, "Failed to assign session", http.StatusInternalServerError)
+		return
+	}
+	if !assigned {
+		http.Error(w, "Session is not awaiting review", http.StatusConflict)
+		return
+	}
+	h.writeReviewState(w, session.Token)
+}
+
+func (h *AdminHandler) writeReviewState(w http.ResponseWriter, token string) {
+	state, err := h.Repo.GetReviewState(token)
+	if err != nil {
+		http.Error(w, "Failed to load review state", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"log"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/aoricaan/idv-core/internal/infra"
+	"github.com/aoricaan/idv-core/internal/service"
+	"github.com/google/uuid"
+)
+
+// recordTransition appends the change from event.FromStatus to the current
+// status to the audit trail of a session. Failing to record it is logged, the
+// transition itself already happened.
+func recordTransition(repo *infra.Repository, session *domain.Session, event domain.SessionEvent) {
+	if event.FromStatus == session.Status {
+		return
+	}
+	event.SessionID = session.ID
+	event.Kind = domain.EventStatusChange
+	event.ToStatus = session.Status
+	if err := repo.AddSessionEvent(&event); err != nil {
+		log.Printf("ERROR: Failed to record event of session %s: %v", session.ID, err)
+	}
+}
+
+// recordDeviceEvent appends a device move (handoff) to the audit trail of a
+// session, the status is unchanged
+func recordDeviceEvent(repo *infra.Repository, session *domain.Session, kind, notes string) {
+	event := domain.SessionEvent{
+		SessionID:  session.ID,
+		Kind:       kind,
+		FromStatus: session.Status,
+		ToStatus:   session.Status,
+		ActorType:  domain.ActorDevice,
+		Notes:      notes,
+	}
+	if err := repo.AddSessionEvent(&event); err != nil {
+		log.Printf("ERROR: Failed to record device event of session %s: %v", session.ID, err)
+	}
+}
+
+// publishSession pushes a session change to the real-time streams of its
+// tenant and of the session
+func publishSession(stream *service.SessionStream, tenantID uuid.UUID, session *domain.Session, event string, from domain.SessionStatus) {
+	stream.Publish(context.Background(), tenantID, service.NewSessionStreamEvent(event, session, from))
+}
+
+// notifySession sends the session webhook including its audit trail
+func notifySession(repo *infra.Repository, webhooks *service.WebhookService, tenant *domain.Tenant, session *domain.Session) {
+	webhooks.Notify(tenant, sessionWebhookEvent(repo, session))
+}
+
+// notifyResubmission tells the tenant the user must redo some steps and where
+func notifyResubmission(repo *infra.Repository, webhooks *service.WebhookService, tenant *domain.Tenant, session *domain.Session, notice service.ResubmissionNotice) {
+	event := sessionWebhookEvent(repo, session)
+	event.Event = "session.resubmission_requested"
+	event.Resubmission = &notice
+	webhooks.Notify(tenant, event)
+}
+
+func sessionWebhookEvent(repo *infra.Repository, session *domain.Session) service.WebhookEvent {
+	events, err := repo.ListSessionEvents(session.ID.String())
+	if err != nil {
+		log.Printf("ERROR: Failed to load events of session %s: %v", session.ID, err)
+	}
+	return service.NewSessionEvent(session, events)
+}
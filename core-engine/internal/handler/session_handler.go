@@ -1,27 +1,48 @@
 package handler
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/aoricaan/idv-core/internal/config"
 	"github.com/aoricaan/idv-core/internal/domain"
 	"github.com/aoricaan/idv-core/internal/infra"
 	"github.com/aoricaan/idv-core/internal/service"
+	"github.com/aoricaan/idv-core/internal/validators"
 	"github.com/google/uuid"
 )
 
 type SessionHandler struct {
-	Repo    *infra.Repository
-	Storage *service.StorageService
+	Repo       *infra.Repository
+	Storage    *service.StorageService
+	Webhooks   *service.WebhookService
+	Images     *service.ImageProcessor
+	OCR        *service.OCRRegistry
+	Biometrics *service.BiometricsRegistry
+	Limiter    *service.RateLimiter   // Optional, nil disables rate limits
+	Stream     *service.SessionStream // Optional, nil disables real-time streams
 }
 
 type InitSessionRequest struct {
 	FlowID        string `json:"flow_id"` // client sends flow name/id
 	UserReference string `json:"user_reference"`
+	SuccessURL    string `json:"success_url,omitempty"` // Must match the tenant redirect allowlist
+	FailureURL    string `json:"failure_url,omitempty"`
+
+	Prefill  map[string]interface{} `json:"prefill,omitempty"`  // Known user data, seeded into collected_data
+	Metadata map[string]interface{} `json:"metadata,omitempty"` // Opaque tenant data, echoed back in results and webhooks
+	Locale   string                 `json:"locale,omitempty"`   // e.g. es-MX. Defaults to the flow locale
 }
 
 type InitSessionResponse struct {
@@ -29,10 +50,35 @@ type InitSessionResponse struct {
 	ExpiresIn   int    `json:"expires_in"`
 }
 
+// PublicTenant is the sanitized tenant view used to white-label the Secure Flow
+type PublicTenant struct {
+	Name             string            `json:"name"`
+	PrimaryColor     string            `json:"primary_color,omitempty"`
+	SecondaryColor   string            `json:"secondary_color,omitempty"`
+	BackgroundColor  string            `json:"background_color,omitempty"`
+	TextColor        string            `json:"text_color,omitempty"`
+	LogoURL          string            `json:"logo_url,omitempty"`
+	FontFamily       string            `json:"font_family,omitempty"`
+	CopyOverrides    map[string]string `json:"copy_overrides,omitempty"`
+	PrivacyPolicyURL string            `json:"privacy_policy_url,omitempty"`
+}
+
 type GetSessionResponse struct {
-	Session  *domain.Session    `json:"session"`
-	NextStep *domain.StepConfig `json:"next_step,omitempty"`
-	Tenant   *domain.Tenant     `json:"tenant,omitempty"`
+	Session     *domain.Session    `json:"session"`
+	NextStep    *domain.StepConfig `json:"next_step,omitempty"`
+	Tenant      *PublicTenant      `json:"tenant,omitempty"`
+	RedirectURL string             `json:"redirect_url,omitempty"` // Signed return URL once the flow is finished
+	Retry       *RetryInstruction  `json:"retry,omitempty"`        // The submitted step must be redone
+	// Set when the session binds to the calling device, send it back in X-Session-Secret
+	DeviceSecret string `json:"device_secret,omitempty"`
+}
+
+// RetryInstruction tells the Secure Flow why the step was not accepted
+type RetryInstruction struct {
+	StepID       string   `json:"step_id"`
+	Field        string   `json:"field,omitempty"`
+	Reasons      []string `json:"reasons"`
+	AttemptsLeft int      `json:"attempts_left"`
 }
 
 type SubmitStepRequest struct {
@@ -52,49 +98,174 @@ func (h *SessionHandler) SubmitStep(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid session", http.StatusNotFound)
 		return
 	}
+	if service.ResubmissionExpired(session) {
+		http.Error(w, "Resubmission link expired", http.StatusGone)
+		return
+	}
+	if !session.AcceptsSubmissions(time.Now()) {
+		writeSessionClosed(w)
+		return
+	}
+
+	// 2. Load Flow & verify the request comes from an allowed Secure Flow host
+	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
+	if err != nil {
+		http.Error(w, "Flow configuration not found", http.StatusInternalServerError)
+		return
+	}
+
+	tenant, err := h.Repo.GetTenantByID(flow.TenantID.String())
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.verifyFlowHost(r, tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !h.allowPublicRequest(w, r, tenant) {
+		return
+	}
+	h.recordAccess(r, session, accessSubmitStep)
+	if !h.authorizeDevice(w, r, session) {
+		return
+	}
 
-	// 2. Decode Data
+	// 3. Decode Data
 	var req SubmitStepRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid body", http.StatusBadRequest)
 		return
 	}
 
-	// 3. Update Session Data (Merge)
+	// Form field validators (CURP, RFC, INE...) declared in the step
+	if session.CurrentStepIndex < len(flow.StepsConfiguration) {
+		step := flow.StepsConfiguration[session.CurrentStepIndex]
+		if issues := validators.ValidateFormFields(step.BaseConfig, req.Data); len(issues) > 0 {
+			h.writeRetry(w, flow, session, &RetryInstruction{StepID: step.StepID, Reasons: issues})
+			return
+		}
+	}
+
+	// Uploaded files must belong to this session and exist in the bucket
+	fileFields, err := h.Storage.VerifySessionUploads(r.Context(), tenant.ID.String(), session.ID.String(), req.Data)
+	if err != nil {
+		var uploadErr *service.UploadError
+		if errors.As(err, &uploadErr) {
+			http.Error(w, fmt.Sprintf("Invalid upload: %v", err), http.StatusBadRequest)
+			return
+		}
+		log.Printf("ERROR: Failed to verify uploads: %v", err)
+		http.Error(w, "Failed to verify uploads", http.StatusInternalServerError)
+		return
+	}
+
+	// Inspect each uploaded image (format, dimensions, metadata, thumbnail)
+	uploads := sessionUploads(session)
+	accepted := maps.Clone(uploads) // Restored when a retake is asked for
+	for _, field := range fileFields {
+		report, err := h.Images.Process(r.Context(), req.Data[field].(string))
+		if err != nil {
+			var imageErr *service.ImageValidationError
+			if errors.As(err, &imageErr) {
+				http.Error(w, fmt.Sprintf("Invalid image for %s: %v", field, err), http.StatusBadRequest)
+				return
+			}
+			log.Printf("ERROR: Failed to process upload %s: %v", field, err)
+			http.Error(w, "Failed to process upload", http.StatusInternalServerError)
+			return
+		}
+		uploads[field] = report
+	}
+	if len(fileFields) > 0 {
+		session.Results["uploads"] = uploads
+	}
+
+	// Capture quality: ask for a retake instead of advancing on bad images
+	if retry := h.checkCaptureQuality(flow, session, fileFields, uploads, accepted); retry != nil {
+		h.writeRetry(w, flow, session, retry)
+		return
+	}
+
+	// OCR with the provider configured for the step
+	h.runStepOCR(r.Context(), flow, session, fileFields, req.Data)
+
+	// Document data: structured fields from the MRZ (typed by the user or read by OCR)
+	if retry := h.extractDocumentData(flow, session, req.Data); retry != nil {
+		h.writeRetry(w, flow, session, retry)
+		return
+	}
+
+	// 4. Update Session Data (Merge)
 	for k, v := range req.Data {
 		session.CollectedData[k] = v
 	}
 
-	// 4. Advance Step
-	session.CurrentStepIndex++
+	// Face match & liveness once both the document and the selfie are in
+	h.runBiometrics(r.Context(), flow, session)
 
-	// 5. Check if Flow is Complete
-	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
-	if err == nil {
-		if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
-			session.Status = domain.StatusReview
-		} else {
-			session.Status = domain.StatusInProgress
-		}
+	// Built-in CODE_STEP validations
+	runCURPValidation(flow, session)
+	h.runBarcodeDecode(r.Context(), flow, session)
+
+	// Same document or person reused by other sessions of the tenant
+	h.runDuplicateCheck(tenant, session)
+
+	// Who is using the session: IPs, devices, automation, country
+	h.runDeviceSignals(session)
+
+	// 5. Advance Step & check if Flow is Complete
+	previousStatus := session.Status
+	var outcome *service.DecisionOutcome
+	session.CurrentStepIndex = service.NextStepIndex(flow, session)
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
+		outcome = decideSession(flow, session)
+		session.Status = outcome.Status
+	} else {
+		session.Status = domain.StatusInProgress
 	}
 
 	// 6. Save
-	if err := h.Repo.UpdateSession(session); err != nil {
+	updated, err := h.Repo.UpdateSession(session)
+	if err != nil {
 		fmt.Printf("ERROR: Failed to update session: %v\n", err)
 		http.Error(w, fmt.Sprintf("Failed to update session: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if !updated {
+		writeSessionClosed(w)
+		return
+	}
 
-	// 7. Return Next State
-	var nextStep *domain.StepConfig
-	if err == nil && session.CurrentStepIndex < len(flow.StepsConfiguration) {
-		step := flow.StepsConfiguration[session.CurrentStepIndex]
-		nextStep = &step
+	if outcome != nil {
+		recordTransition(h.Repo, session, domain.SessionEvent{
+			FromStatus: previousStatus,
+			ActorType:  domain.ActorSystem,
+			ActorID:    "decision_policy",
+			ReasonCode: firstReason(outcome.Reasons),
+			Notes:      strings.Join(outcome.Reasons, ", "),
+		})
+	} else {
+		recordTransition(h.Repo, session, domain.SessionEvent{FromStatus: previousStatus, ActorType: domain.ActorSystem})
 	}
+	if session.Status != previousStatus {
+		publishSession(h.Stream, tenant.ID, session, service.StreamSessionStatusChanged, previousStatus)
+	} else {
+		publishSession(h.Stream, tenant.ID, session, service.StreamSessionProgressed, previousStatus)
+	}
+
+	if session.Status.IsFinal() {
+		notifySession(h.Repo, h.Webhooks, tenant, session)
+	}
+
+	// 7. Return Next State
+	nextStep := h.currentStep(flow, session)
 
 	resp := GetSessionResponse{
-		Session:  session,
-		NextStep: nextStep,
+		Session:     publicSession(session),
+		NextStep:    nextStep,
+		RedirectURL: h.returnRedirect(session, tenant),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -114,6 +285,10 @@ func (h *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid or expired session", http.StatusNotFound)
 		return
 	}
+	if service.ResubmissionExpired(session) {
+		http.Error(w, "Resubmission link expired", http.StatusGone)
+		return
+	}
 
 	// Fetch Flow to get the Step Config
 	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
@@ -122,15 +297,37 @@ func (h *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var nextStep *domain.StepConfig
-	if session.CurrentStepIndex < len(flow.StepsConfiguration) {
-		step := flow.StepsConfiguration[session.CurrentStepIndex]
-		nextStep = &step
+	tenant, err := h.Repo.GetTenantByID(flow.TenantID.String())
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusInternalServerError)
+		return
 	}
 
+	if err := h.verifyFlowHost(r, tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !h.allowPublicRequest(w, r, tenant) {
+		return
+	}
+	endpoint := accessGetSession
+	if r.URL.Query().Has("handoff") {
+		endpoint = accessHandoff
+	}
+	h.recordAccess(r, session, endpoint)
+	deviceSecret, ok := h.openSession(w, r, tenant, session)
+	if !ok {
+		return
+	}
+
+	nextStep := h.currentStep(flow, session)
+
 	resp := GetSessionResponse{
-		Session:  session,
-		NextStep: nextStep,
+		Session:      publicSession(session),
+		NextStep:     nextStep,
+		Tenant:       h.publicTenant(r.Context(), tenant),
+		RedirectURL:  h.returnRedirect(session, tenant),
+		DeviceSecret: deviceSecret,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -141,24 +338,8 @@ func (h *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 
 func (h *SessionHandler) InitSession(w http.ResponseWriter, r *http.Request) {
 	// 1. Validate Auth Header
-	apiKey := r.Header.Get("Authorization")
-	if apiKey == "" {
-		http.Error(w, "Missing Authorization Header", http.StatusUnauthorized)
-		return
-	}
-
-	// Hash the key to look it up
-	hasher := sha256.New()
-	hasher.Write([]byte(apiKey))
-	apiKeyHash := hex.EncodeToString(hasher.Sum(nil))
-
-	// DEBUG LOG
-	fmt.Printf("DEBUG: Received Key: %s, Calculated Hash: %s\n", apiKey, apiKeyHash)
-
-	tenant, err := h.Repo.GetTenantByAPIKeyHash(apiKeyHash)
-	if err != nil {
-		fmt.Printf("DEBUG: Repo Error: %v\n", err)
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	tenant, ok := h.authenticateAPIKey(w, r)
+	if !ok {
 		return
 	}
 
@@ -169,7 +350,43 @@ func (h *SessionHandler) InitSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 3. Check Credits & Deduct
+	// Velocity limits, a leaked key must not drain the credits
+	if !h.allowSessionCreation(w, r, tenant, req.UserReference) {
+		return
+	}
+
+	// 3. Validate Return URLs against the tenant allowlist
+	for _, returnURL := range []string{req.SuccessURL, req.FailureURL} {
+		if returnURL == "" {
+			continue
+		}
+		if err := service.ValidateReturnURL(returnURL, tenant.AllowedRedirectDomains); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid return URL: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Prefill & Metadata: type and size limits
+	if err := service.ValidatePrefill(req.Prefill); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid prefill: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateMetadata(req.Metadata); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid metadata: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	locale := ""
+	if req.Locale != "" {
+		l, err := service.NormalizeLocale(req.Locale)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		locale = l
+	}
+
+	// 4. Check Credits & Deduct
 	if tenant.CreditsBalance <= 0 {
 		http.Error(w, "Insufficient credits", http.StatusPaymentRequired)
 		return
@@ -180,7 +397,7 @@ func (h *SessionHandler) InitSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Find Flow
+	// 5. Find Flow
 	// For MVP, we assume the Client sends the FLOW NAME or ID.
 	// Adapting to use FlowName if ID is not UUID, or just simple FlowName lookup
 	flow, err := h.Repo.GetFlowByName(tenant.ID.String(), req.FlowID)
@@ -189,17 +406,32 @@ func (h *SessionHandler) InitSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Create Session
+	// 6. Create Session
 	token := uuid.New().String() // Using UUID as token for now. In prod use crypto/rand
 	expiresIn := 900             // 15 minutes
 
+	collectedData := domain.JSONB{}
+	for k, v := range req.Prefill {
+		collectedData[k] = v
+	}
+
+	metadata := domain.JSONB{}
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+
 	session := &domain.Session{
+		ID:            uuid.New(),
 		Token:         token,
 		FlowID:        flow.ID,
 		UserReference: req.UserReference,
 		Status:        domain.StatusPending,
 		ExpiresAt:     time.Now().Add(time.Duration(expiresIn) * time.Second),
-		CollectedData: domain.JSONB{},
+		CollectedData: collectedData,
+		Metadata:      metadata,
+		Locale:        locale,
+		SuccessURL:    req.SuccessURL,
+		FailureURL:    req.FailureURL,
 	}
 
 	if err := h.Repo.CreateSession(session); err != nil {
@@ -207,10 +439,12 @@ func (h *SessionHandler) InitSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
 		return
 	}
+	recordTransition(h.Repo, session, domain.SessionEvent{ActorType: domain.ActorAPIKey, ActorID: tenant.APIKeyLast4})
+	publishSession(h.Stream, tenant.ID, session, service.StreamSessionCreated, "")
 
-	// 5. Response
+	// 7. Response
 	resp := InitSessionResponse{
-		RedirectURL: fmt.Sprintf("http://localhost:3000/start?token=%s", token),
+		RedirectURL: service.BuildFlowStartURL(config.GetSecureFlowBaseURL(), tenant.CustomDomain, token),
 		ExpiresIn:   expiresIn,
 	}
 
@@ -219,13 +453,17 @@ func (h *SessionHandler) InitSession(w http.ResponseWriter, r *http.Request) {
 }
 
 type UploadURLRequest struct {
-	Filename    string `json:"filename"`
-	ContentType string `json:"content_type"`
+	ContentType string `json:"content_type"` // Must be one of the allowed image types
 }
 
+// UploadURLResponse describes a presigned POST: the browser sends FormData fields
+// followed by the file (field name "file") to UploadURL.
 type UploadURLResponse struct {
-	UploadURL string `json:"upload_url"`
-	FileKey   string `json:"file_key"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	FormData  map[string]string `json:"form_data"`
+	FileKey   string            `json:"file_key"`
+	MaxSize   int64             `json:"max_size"`
 }
 
 func (h *SessionHandler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
@@ -240,6 +478,34 @@ func (h *SessionHandler) GenerateUploadURL(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid session", http.StatusNotFound)
 		return
 	}
+	if service.ResubmissionExpired(session) {
+		http.Error(w, "Resubmission link expired", http.StatusGone)
+		return
+	}
+
+	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
+	if err != nil {
+		http.Error(w, "Flow configuration not found", http.StatusInternalServerError)
+		return
+	}
+
+	tenant, err := h.Repo.GetTenantByID(flow.TenantID.String())
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.verifyFlowHost(r, tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !h.allowPublicRequest(w, r, tenant) {
+		return
+	}
+	h.recordAccess(r, session, accessUploadURL)
+	if !h.authorizeDevice(w, r, session) {
+		return
+	}
 
 	var req UploadURLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -247,28 +513,567 @@ func (h *SessionHandler) GenerateUploadURL(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// TODO: Validate content type (e.g. image/jpeg, image/png only)
-
-	// Fetch Tenant to use ID in path (optimization: could add TenantID to Session struct)
-	// For MVP, using default tenant ID. Ideally we validate flow ownership.
+	// Only images are accepted. The object name is generated server side.
+	if _, ok := service.AllowedUploadTypes[req.ContentType]; !ok {
+		http.Error(w, "Unsupported content type", http.StatusBadRequest)
+		return
+	}
 
-	uploadURL, fileKey, err := h.Storage.GeneratePresignedUploadURL(
+	uploadURL, formData, fileKey, err := h.Storage.GeneratePresignedUpload(
 		r.Context(),
-		"tenant-default", // TODO: Fetch real tenant ID from Flow
-		session.Token,
-		req.Filename,
+		tenant.ID.String(),
+		session.ID.String(),
+		req.ContentType,
 	)
 	if err != nil {
+		log.Printf("ERROR: Failed to generate upload URL: %v", err)
 		http.Error(w, "Failed to generate upload URL", http.StatusInternalServerError)
 		return
 	}
 
 	resp := UploadURLResponse{
 		UploadURL: uploadURL,
+		Method:    http.MethodPost,
+		FormData:  formData,
 		FileKey:   fileKey,
+		MaxSize:   service.MaxUploadSize,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*") // CORS
 	json.NewEncoder(w).Encode(resp)
 }
+
+// returnRedirect builds the signed redirect back to the tenant app, if the flow is finished.
+func (h *SessionHandler) returnRedirect(session *domain.Session, tenant *domain.Tenant) string {
+	if !session.Status.IsFinal() {
+		return ""
+	}
+
+	redirectURL, err := service.BuildReturnRedirect(session, tenant.SigningSecret)
+	if err != nil {
+		log.Printf("ERROR: Failed to build return redirect: %v", err)
+		return ""
+	}
+	return redirectURL
+}
+
+// verifyFlowHost makes sure a public Secure Flow request is served from the platform
+// host or the tenant's own custom domain, never from another tenant's domain.
+func (h *SessionHandler) verifyFlowHost(r *http.Request, tenant *domain.Tenant) error {
+	baseURL := config.GetSecureFlowBaseURL()
+
+	// Browser requests carry the page origin
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !service.IsAllowedFlowHost(u.Hostname(), baseURL, tenant.CustomDomain) {
+			return fmt.Errorf("origin %s is not allowed for this session", origin)
+		}
+		return nil
+	}
+
+	// Same-origin deployments (API behind the custom domain) only send Host
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+	if host == "" || host == tenant.CustomDomain {
+		return nil
+	}
+	if other, err := h.Repo.GetTenantByCustomDomain(host); err == nil && other.ID != tenant.ID {
+		return fmt.Errorf("host %s is not allowed for this session", host)
+	}
+	return nil
+}
+
+// writeRetry saves the session (attempt counters) and sends the user back to the same step
+func (h *SessionHandler) writeRetry(w http.ResponseWriter, flow *domain.Flow, session *domain.Session, retry *RetryInstruction) {
+	updated, err := h.Repo.UpdateSession(session)
+	if err != nil {
+		log.Printf("ERROR: Failed to record step attempt: %v", err)
+	} else if !updated {
+		writeSessionClosed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(GetSessionResponse{
+		Session:  publicSession(session),
+		NextStep: h.currentStep(flow, session),
+		Retry:    retry,
+	})
+}
+
+// writeSessionClosed answers submissions to a session that was decided or
+// expired: the flow can't be replayed over a decision
+func writeSessionClosed(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Error(w, "This verification no longer accepts submissions", http.StatusConflict)
+}
+
+// extractDocumentData parses the "mrz" value of a document step and stores the
+// structured fields in results["document"]. The raw MRZ is not kept.
+func (h *SessionHandler) extractDocumentData(flow *domain.Flow, session *domain.Session, data map[string]interface{}) *RetryInstruction {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
+		return nil
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+	if step.Type != "document_scan" && step.Type != "document_capture" {
+		return nil
+	}
+	mrz, _ := data["mrz"].(string)
+	delete(data, "mrz")
+	if strings.TrimSpace(mrz) == "" {
+		return nil
+	}
+
+	doc, err := service.ParseMRZ(mrz, time.Now())
+	if err != nil {
+		reasons := []string{err.Error()}
+		var mrzErr *service.MRZError
+		if errors.As(err, &mrzErr) {
+			reasons = mrzErr.Reasons
+		}
+		return &RetryInstruction{StepID: step.StepID, Field: "mrz", Reasons: reasons}
+	}
+	session.Results["document"] = doc
+	return nil
+}
+
+// runStepOCR sends the images of a document step to its OCR provider and records
+// every run in results["ocr"][step_id]. A MRZ read by OCR is used unless
+// the user typed one. OCR failures never block the user, the reviewer sees them.
+func (h *SessionHandler) runStepOCR(ctx context.Context, flow *domain.Flow, session *domain.Session, fileFields []string, data map[string]interface{}) {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) || len(fileFields) == 0 {
+		return
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+	if step.Type != "document_scan" && step.Type != "document_capture" {
+		return
+	}
+	provider, err := h.OCR.ForStep(step.Config)
+	if err != nil {
+		log.Printf("WARNING: OCR disabled for step %s: %v", step.StepID, err)
+		return
+	}
+	if provider == nil {
+		return
+	}
+
+	var runs []service.OCRRun
+	for _, field := range fileFields {
+		key := service.ProcessedObjectKey(sessionUploads(session), field, data[field].(string))
+		image, err := h.Storage.GetObject(ctx, key, service.MaxUploadSize)
+		if err != nil {
+			log.Printf("ERROR: Failed to read %s for OCR: %v", key, err)
+			continue
+		}
+		side, _ := step.Config["side"].(string)
+		req := service.OCRRequest{Image: image, ContentType: http.DetectContentType(image), Side: side}
+		if report, ok := sessionUploads(session)[field].(*service.ImageReport); ok {
+			req.UploadSHA256 = report.UploadSHA256
+		}
+		run := service.RunOCR(ctx, provider, field, req)
+		if run.Error != "" {
+			log.Printf("WARNING: OCR provider %s failed: %s", run.Provider, run.Error)
+		}
+		if mrz, _ := data["mrz"].(string); strings.TrimSpace(mrz) == "" && run.MRZ != "" {
+			data["mrz"] = run.MRZ
+		}
+		runs = append(runs, run)
+	}
+
+	ocr, _ := session.Results["ocr"].(map[string]interface{})
+	if ocr == nil {
+		ocr = map[string]interface{}{}
+	}
+	ocr[step.StepID] = runs
+	session.Results["ocr"] = ocr
+}
+
+// runBiometrics executes a face_match step: the document photo is compared with
+// the selfie and, unless disabled, the selfie goes through a liveness check.
+// The result is stored in results["biometrics"].
+func (h *SessionHandler) runBiometrics(ctx context.Context, flow *domain.Flow, session *domain.Session) {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
+		return
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+	if step.Type != "face_match" {
+		return
+	}
+	documentKey, _ := session.CollectedData["document_front"].(string)
+	selfieKey, _ := session.CollectedData["selfie"].(string)
+	if documentKey == "" || selfieKey == "" {
+		return
+	}
+	uploads := sessionUploads(session)
+	documentKey = service.ProcessedObjectKey(uploads, "document_front", documentKey)
+	selfieKey = service.ProcessedObjectKey(uploads, "selfie", selfieKey)
+
+	matcher, checker, err := h.Biometrics.ForStep(step.Config)
+	if err != nil {
+		log.Printf("WARNING: Face match unavailable for step %s: %v", step.StepID, err)
+		session.Results["biometrics"] = service.UnavailableBiometrics(step.StepID, err)
+		return
+	}
+	document, err := h.Storage.GetObject(ctx, documentKey, service.MaxUploadSize)
+	if err != nil {
+		log.Printf("ERROR: Failed to read %s for face match: %v", documentKey, err)
+		return
+	}
+	selfie, err := h.Storage.GetObject(ctx, selfieKey, service.MaxUploadSize)
+	if err != nil {
+		log.Printf("ERROR: Failed to read %s for face match: %v", selfieKey, err)
+		return
+	}
+
+	thresholds := service.BiometricThresholdsFor(step.Config)
+	result := &service.BiometricResult{
+		StepID:    step.StepID,
+		FaceMatch: service.RunFaceMatch(ctx, matcher, thresholds, document, selfie),
+		RanAt:     time.Now(),
+	}
+	if thresholds.Liveness {
+		if checker != nil {
+			result.Liveness = service.RunLiveness(ctx, checker, thresholds, selfie)
+		} else {
+			result.Liveness = service.UnavailableLiveness(matcher.Name())
+		}
+	}
+	result.Decision = result.Combine()
+	session.Results["biometrics"] = result
+}
+
+// runCURPValidation executes a curp_validation step on the CURP collected by a
+// previous form (config.field, "curp" by default) and cross-checks the date of
+// birth against the form or the document MRZ. The user is never blocked here,
+// the result in results["curp_validation"] is for review and rules.
+func runCURPValidation(flow *domain.Flow, session *domain.Session) {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
+		return
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+	if step.Type != "curp_validation" {
+		return
+	}
+	field, _ := step.Config["field"].(string)
+	if field == "" {
+		field = "curp"
+	}
+	curp, _ := session.CollectedData[field].(string)
+	if curp == "" {
+		session.Results["curp_validation"] = validators.CURPCheck{Errors: []string{"CURP was not provided"}}
+		return
+	}
+
+	dob, _ := session.CollectedData["date_of_birth"].(string)
+	if dob == "" {
+		switch doc := session.Results["document"].(type) {
+		case *service.MRZDocument:
+			dob = doc.DateOfBirth
+		case map[string]interface{}:
+			dob, _ = doc["date_of_birth"].(string)
+		}
+	}
+	session.Results["curp_validation"] = validators.CheckCURP(curp, dob)
+}
+
+// runDuplicateCheck stores the fingerprints of the session and raises a risk
+// signal when another user reference of the tenant shares one. Lookup failures
+// are logged, they never block the user.
+func (h *SessionHandler) runDuplicateCheck(tenant *domain.Tenant, session *domain.Session) {
+	fingerprints := service.SessionFingerprints(tenant.ID.String(), session.Results)
+	if len(fingerprints) == 0 {
+		return
+	}
+	if err := h.Repo.ReplaceSessionFingerprints(tenant.ID.String(), session.ID.String(), fingerprints); err != nil {
+		log.Printf("ERROR: Failed to store fingerprints of session %s: %v", session.ID, err)
+		return
+	}
+	matches, err := h.Repo.FindDuplicateSessions(session.ID.String(), service.DuplicateImageDistance)
+	if err != nil {
+		log.Printf("ERROR: Failed to look up duplicates of session %s: %v", session.ID, err)
+		return
+	}
+	service.AddNewRiskSignals(session.Results, service.DuplicateSignals(session.UserReference, matches)...)
+}
+
+// runBarcodeDecode executes a barcode_decode step: the PDF417 barcode on the
+// back of the document (config.field, "document_back" by default) is decoded
+// and compared with the front. Unreadable barcodes, expired documents and
+// mismatches are recorded as risk signals, the user is never blocked here.
+func (h *SessionHandler) runBarcodeDecode(ctx context.Context, flow *domain.Flow, session *domain.Session) {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
+		return
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+	if step.Type != "barcode_decode" {
+		return
+	}
+	field, _ := step.Config["field"].(string)
+	if field == "" {
+		field = "document_back"
+	}
+	key, _ := session.CollectedData[field].(string)
+	if key == "" {
+		return
+	}
+	key = service.ProcessedObjectKey(sessionUploads(session), field, key)
+	data, err := h.Storage.GetObject(ctx, key, service.MaxUploadSize)
+	if err != nil {
+		log.Printf("ERROR: Failed to read %s for barcode decoding: %v", key, err)
+		return
+	}
+
+	result := &service.BarcodeResult{StepID: step.StepID, Field: field, Format: "pdf417", DecodedAt: time.Now()}
+	license, err := service.DecodeLicenseBarcode(data, time.Now())
+	if err != nil {
+		result.Error = err.Error()
+		service.AddRiskSignals(session.Results, service.RiskSignal{
+			Code: "barcode_unreadable", Severity: service.RiskLow, Source: step.StepID, Detail: err.Error(),
+		})
+		session.Results["barcode"] = result
+		return
+	}
+	result.License = license
+	result.Mismatches = service.CompareDocumentFields(license.Fields(), service.FrontDocumentFields(session.Results))
+
+	var signals []service.RiskSignal
+	if license.Expired {
+		signals = append(signals, service.RiskSignal{Code: "document_expired", Severity: service.RiskMedium, Source: step.StepID, Detail: "expiry " + license.ExpiryDate})
+	}
+	for _, m := range result.Mismatches {
+		signals = append(signals, service.RiskSignal{
+			Code:     "barcode_front_mismatch",
+			Severity: service.RiskHigh,
+			Source:   step.StepID,
+			Detail:   fmt.Sprintf("%s: front %q, barcode %q", m.Field, m.Expected, m.Actual),
+		})
+	}
+	service.AddRiskSignals(session.Results, signals...)
+	session.Results["barcode"] = result
+}
+
+// decideSession runs the flow decision policy on a session that finished its
+// last step and records the outcome in results["decision"]. A policy
+// that can't be evaluated sends the session to review.
+func decideSession(flow *domain.Flow, session *domain.Session) *service.DecisionOutcome {
+	outcome, err := service.EvaluatePolicy(service.PolicyFor(flow), session)
+	if err != nil {
+		log.Printf("ERROR: Decision policy of flow %s failed: %v", flow.ID, err)
+		outcome = &service.DecisionOutcome{Status: domain.StatusReview, Reasons: []string{"policy_error"}, MatchedRules: []string{}, DecidedAt: time.Now()}
+	}
+	session.Results["decision"] = outcome
+	return outcome
+}
+
+func firstReason(reasons []string) string {
+	if len(reasons) == 0 {
+		return ""
+	}
+	return reasons[0]
+}
+
+// checkCaptureQuality compares the quality of the images just uploaded with the
+// thresholds of the current step. Attempts are counted per step in the session
+// results; once max_attempts is reached the capture is accepted with its issues
+// recorded. A retake discards the submission, so the upload reports go back to
+// the accepted ones.
+func (h *SessionHandler) checkCaptureQuality(flow *domain.Flow, session *domain.Session, fileFields []string, uploads, accepted map[string]interface{}) *RetryInstruction {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) || len(fileFields) == 0 {
+		return nil
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+	thresholds := service.QualityThresholdsFor(step.Type, step.Config)
+	if !thresholds.Enabled {
+		return nil
+	}
+
+	attempts, _ := session.Results["capture_attempts"].(map[string]interface{})
+	if attempts == nil {
+		attempts = map[string]interface{}{}
+	}
+	used := 0
+	if v, ok := attempts[step.StepID].(float64); ok {
+		used = int(v)
+	}
+
+	for _, field := range fileFields {
+		report, ok := uploads[field].(*service.ImageReport)
+		if !ok {
+			continue
+		}
+		issues := service.QualityIssues(report.Quality, thresholds)
+		if len(issues) == 0 {
+			continue
+		}
+
+		used++
+		attempts[step.StepID] = float64(used)
+		session.Results["capture_attempts"] = attempts
+
+		if thresholds.MaxAttempts > 0 && used >= thresholds.MaxAttempts {
+			// Out of retries: accept it and let the reviewer decide
+			report.QualityIssues = issues
+			continue
+		}
+
+		for _, f := range fileFields {
+			if prev, ok := accepted[f]; ok {
+				uploads[f] = prev
+			} else {
+				delete(uploads, f)
+			}
+		}
+		attemptsLeft := 0
+		if thresholds.MaxAttempts > 0 {
+			attemptsLeft = thresholds.MaxAttempts - used
+		}
+		return &RetryInstruction{StepID: step.StepID, Field: field, Reasons: issues, AttemptsLeft: attemptsLeft}
+	}
+	return nil
+}
+
+// sessionUploads returns the per-file processing reports recorded in the session results
+func sessionUploads(session *domain.Session) map[string]interface{} {
+	if uploads, ok := session.Results["uploads"].(map[string]interface{}); ok {
+		return uploads
+	}
+	return map[string]interface{}{}
+}
+
+// currentStep returns the step the user has to complete next, localized into the
+// session locale and with form defaults resolved from the data already collected
+// (e.g. tenant prefill).
+func (h *SessionHandler) currentStep(flow *domain.Flow, session *domain.Session) *domain.StepConfig {
+	if session.CurrentStepIndex >= len(flow.StepsConfiguration) {
+		return nil
+	}
+	step := flow.StepsConfiguration[session.CurrentStepIndex]
+
+	tables := []domain.Translations{flow.Translations}
+	if step.TemplateID != nil {
+		if tpl, err := h.Repo.GetStepTemplateByID(step.TemplateID.String(), flow.TenantID.String()); err == nil {
+			tables = append(tables, tpl.Translations)
+		}
+	}
+	chain := service.LocaleChain(session.Locale, flow.DefaultLocale)
+	step.Config = service.LocalizeConfig(step.Config, chain, tables...)
+	step.BaseConfig = service.LocalizeConfig(step.BaseConfig, chain, tables...)
+
+	if step.BaseConfig != nil {
+		service.ApplyFormDefaults(step.BaseConfig, session.CollectedData)
+	}
+	return &step
+}
+
+// publicTenant builds the branding payload. Values were sanitized when saved but are
+// re-checked here since branding_config may have been seeded directly in the DB.
+func (h *SessionHandler) publicTenant(ctx context.Context, tenant *domain.Tenant) *PublicTenant {
+	branding, err := service.SanitizeBranding(service.BrandingFromConfig(tenant.BrandingConfig))
+	if err != nil {
+		log.Printf("WARNING: Ignoring invalid branding for tenant %s: %v", tenant.ID, err)
+		branding = domain.Branding{}
+	}
+
+	public := &PublicTenant{
+		Name:             tenant.Name,
+		PrimaryColor:     branding.PrimaryColor,
+		SecondaryColor:   branding.SecondaryColor,
+		BackgroundColor:  branding.BackgroundColor,
+		TextColor:        branding.TextColor,
+		FontFamily:       branding.FontFamily,
+		CopyOverrides:    branding.CopyOverrides,
+		PrivacyPolicyURL: branding.PrivacyPolicyURL,
+	}
+	if branding.LogoKey != "" {
+		if logoURL, err := h.Storage.GeneratePresignedGetURL(ctx, branding.LogoKey); err == nil {
+			public.LogoURL = logoURL
+		}
+	}
+	return public
+}
+
+// publicSession strips tenant-only data before a session is sent to the browser
+func publicSession(session *domain.Session) *domain.Session {
+	public := *session
+	public.Metadata = nil
+	public.Results = nil
+	return &public
+}
+
+// authenticateAPIKey resolves the tenant for server-to-server calls. On failure the
+// error response is already written.
+func (h *SessionHandler) authenticateAPIKey(w http.ResponseWriter, r *http.Request) (*domain.Tenant, bool) {
+	apiKey := r.Header.Get("Authorization")
+	if apiKey == "" {
+		http.Error(w, "Missing Authorization Header", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Hash the key to look it up
+	hasher := sha256.New()
+	hasher.Write([]byte(apiKey))
+	apiKeyHash := hex.EncodeToString(hasher.Sum(nil))
+
+	tenant, err := h.Repo.GetTenantByAPIKeyHash(apiKeyHash)
+	if err != nil {
+		log.Printf("WARNING: API key lookup failed: %v", err)
+		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+		return nil, false
+	}
+	return tenant, true
+}
+
+type SessionResultResponse struct {
+	SessionID     string               `json:"session_id"`
+	UserReference string               `json:"user_reference"`
+	Status        domain.SessionStatus `json:"status"`
+	CollectedData domain.JSONB         `json:"collected_data"`
+	Results       domain.JSONB         `json:"results"`
+	Metadata      domain.JSONB         `json:"metadata"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// GetSessionResult lets the tenant backend fetch the outcome of a session by its ID
+func (h *SessionHandler) GetSessionResult(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := h.authenticateAPIKey(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		http.Error(w, "Missing session_id", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.Repo.GetSessionByID(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	flow, err := h.Repo.GetFlowByID(session.FlowID.String())
+	if err != nil || flow.TenantID != tenant.ID {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	resp := SessionResultResponse{
+		SessionID:     session.ID.String(),
+		UserReference: session.UserReference,
+		Status:        session.Status,
+		CollectedData: session.CollectedData,
+		Results:       session.Results,
+		Metadata:      session.Metadata,
+		CreatedAt:     session.CreatedAt,
+		UpdatedAt:     session.UpdatedAt,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
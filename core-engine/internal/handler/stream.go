@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comment sent on idle streams so proxies keep the connection open
+const streamKeepAlive = 25 * time.Second
+
+// startStream sends the Server-Sent Events headers. It fails when the
+// connection cannot be flushed.
+func startStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+	return flusher, true
+}
+
+func writeStreamEvent(w http.ResponseWriter, flusher http.Flusher, event string, data []byte) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	flusher.Flush()
+}
+
+// StreamSessions streams the lifecycle changes of the tenant's sessions
+// (service.SessionStreamEvent, event "session") until the client disconnects.
+func (h *AdminHandler) StreamSessions(w http.ResponseWriter, r *http.Request) {
+	tenantIDStr, _ := r.Context().Value(TenantIDKey).(string)
+	tenantID, err := uuid.Parse(tenantIDStr)
+	if err != nil {
+		http.Error(w, "Invalid tenant", http.StatusUnauthorized)
+		return
+	}
+	if h.Stream == nil {
+		http.Error(w, "Streaming not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	changes, stop := h.Stream.SubscribeTenant(tenantID)
+	defer stop()
+	flusher, ok := startStream(w)
+	if !ok {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case payload := <-changes:
+			writeStreamEvent(w, flusher, "session", payload)
+		}
+	}
+}
+
+// StreamSession pushes the progress of a session (SessionProgressResponse,
+// event "progress") to its device or to the device that handed it over: the
+// current progress first, then after every change. The stream ends when the
+// flow finishes or the device loses access.
+func (h *SessionHandler) StreamSession(w http.ResponseWriter, r *http.Request) {
+	session, flow, tenant, ok := h.loadWatchedSession(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.sessionProgress(r, flow, tenant, session); !ok {
+		h.writeDeviceDenied(w, "This verification is open on another device")
+		return
+	}
+	if h.Stream == nil {
+		http.Error(w, "Streaming not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	changes, stop := h.Stream.SubscribeSession(session.ID)
+	defer stop()
+	flusher, ok := startStream(w)
+	if !ok {
+		return
+	}
+
+	// Reloaded after subscribing so no change is missed
+	send := func() bool {
+		current, err := h.Repo.GetSessionByToken(session.Token)
+		if err != nil {
+			return false
+		}
+		progress, ok := h.sessionProgress(r, flow, tenant, current)
+		if !ok {
+			return false
+		}
+		data, _ := json.Marshal(progress)
+		writeStreamEvent(w, flusher, "progress", data)
+		return !progress.Finished
+	}
+	if !send() {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-changes:
+			if !send() {
+				return
+			}
+		}
+	}
+}
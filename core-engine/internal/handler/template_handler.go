@@ -95,7 +95,10 @@ func (h *TemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var req domain.StepTemplate
+	var req struct {
+		domain.StepTemplate
+		Translations *domain.Translations `json:"translations"` // Kept when left out
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
@@ -105,6 +108,9 @@ func (h *TemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request)
 	existing.Name = req.Name
 	existing.Description = req.Description
 	existing.BaseConfig = req.BaseConfig
+	if req.Translations != nil {
+		existing.Translations = *req.Translations
+	}
 
 	if err := h.Repo.UpdateStepTemplate(existing); err != nil {
 		http.Error(w, "Failed to update template", http.StatusInternalServerError)
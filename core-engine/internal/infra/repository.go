@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Repository struct {
@@ -20,8 +22,8 @@ func NewRepository(db *sql.DB) *Repository {
 
 func (r *Repository) GetTenantByAPIKeyHash(hash string) (*domain.Tenant, error) {
 	var t domain.Tenant
-	query := `SELECT id, name, branding_config, credits_balance FROM tenants WHERE api_key_hash = $1`
-	err := r.db.QueryRow(query, hash).Scan(&t.ID, &t.Name, &t.BrandingConfig, &t.CreditsBalance)
+	query := `SELECT id, name, branding_config, credits_balance, allowed_redirect_domains, COALESCE(signing_secret, ''), CASE WHEN custom_domain_verified_at IS NOT NULL THEN custom_domain ELSE '' END, COALESCE(rate_limits, '{}') FROM tenants WHERE api_key_hash = $1`
+	err := r.db.QueryRow(query, hash).Scan(&t.ID, &t.Name, &t.BrandingConfig, &t.CreditsBalance, pq.Array(&t.AllowedRedirectDomains), &t.SigningSecret, &t.CustomDomain, &t.RateLimits)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("tenant not found")
 	}
@@ -41,8 +43,8 @@ func (r *Repository) RotateTenantAPIKey(tenantID string, newHash string, last4 s
 func (r *Repository) GetTenantByID(id string) (*domain.Tenant, error) {
 	var t domain.Tenant
 	var last4 sql.NullString
-	query := `SELECT id, name, branding_config, api_key_last_4, credits_balance FROM tenants WHERE id = $1`
-	err := r.db.QueryRow(query, id).Scan(&t.ID, &t.Name, &t.BrandingConfig, &last4, &t.CreditsBalance)
+	query := `SELECT id, name, COALESCE(webhook_url, ''), branding_config, api_key_last_4, credits_balance, allowed_redirect_domains, COALESCE(signing_secret, ''), CASE WHEN custom_domain_verified_at IS NOT NULL THEN custom_domain ELSE '' END, COALESCE(rate_limits, '{}') FROM tenants WHERE id = $1`
+	err := r.db.QueryRow(query, id).Scan(&t.ID, &t.Name, &t.WebhookURL, &t.BrandingConfig, &last4, &t.CreditsBalance, pq.Array(&t.AllowedRedirectDomains), &t.SigningSecret, &t.CustomDomain, &t.RateLimits)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("tenant not found")
 	}
@@ -55,10 +57,81 @@ func (r *Repository) GetTenantByID(id string) (*domain.Tenant, error) {
 	return &t, nil
 }
 
+func (r *Repository) UpdateTenantRedirectSettings(tenantID string, domains []string) error {
+	query := `UPDATE tenants SET allowed_redirect_domains = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, pq.Array(domains), tenantID)
+	return err
+}
+
+func (r *Repository) UpdateTenantBranding(tenantID string, branding domain.JSONB) error {
+	query := `UPDATE tenants SET branding_config = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, branding, tenantID)
+	return err
+}
+
+func (r *Repository) UpdateTenantWebhookURL(tenantID string, webhookURL string) error {
+	query := `UPDATE tenants SET webhook_url = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, webhookURL, tenantID)
+	return err
+}
+
+// GetTenantByCustomDomain only returns the fields needed to resolve a Secure Flow host
+func (r *Repository) GetTenantByCustomDomain(host string) (*domain.Tenant, error) {
+	var t domain.Tenant
+	query := `SELECT id, name, custom_domain FROM tenants WHERE custom_domain = $1 AND custom_domain_verified_at IS NOT NULL`
+	err := r.db.QueryRow(query, host).Scan(&t.ID, &t.Name, &t.CustomDomain)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("tenant not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTenantCustomDomain returns the custom domain claimed by the tenant, verified or not
+func (r *Repository) GetTenantCustomDomain(tenantID string) (*domain.CustomDomainClaim, error) {
+	var c domain.CustomDomainClaim
+	query := `SELECT COALESCE(custom_domain, ''), COALESCE(custom_domain_token, ''), custom_domain_verified_at FROM tenants WHERE id = $1`
+	err := r.db.QueryRow(query, tenantID).Scan(&c.Domain, &c.Token, &c.VerifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("tenant not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UpdateTenantCustomDomain claims (unverified, with a new challenge token) or
+// clears (empty string) the tenant custom domain
+func (r *Repository) UpdateTenantCustomDomain(tenantID string, customDomain string, token string) error {
+	query := `
+		UPDATE tenants SET custom_domain = NULLIF($1, ''), custom_domain_token = NULLIF($2, ''), custom_domain_verified_at = NULL, updated_at = NOW()
+		WHERE id = $3
+	`
+	_, err := r.db.Exec(query, customDomain, token, tenantID)
+	return err
+}
+
+// VerifyTenantCustomDomain marks the claimed domain as verified. It fails
+// (false) if the tenant claimed another domain meanwhile, and with a unique
+// violation if another tenant verified it first.
+func (r *Repository) VerifyTenantCustomDomain(tenantID string, customDomain string) (bool, error) {
+	query := `UPDATE tenants SET custom_domain_verified_at = NOW(), updated_at = NOW() WHERE id = $1 AND custom_domain = $2`
+	return r.execAffected(query, tenantID, customDomain)
+}
+
+// IsUniqueViolation reports whether err is a Postgres unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 func (r *Repository) GetFlowByName(tenantID string, flowName string) (*domain.Flow, error) {
 	var f domain.Flow
-	query := `SELECT id, name, steps_configuration FROM flows WHERE tenant_id = $1 AND name = $2`
-	err := r.db.QueryRow(query, tenantID, flowName).Scan(&f.ID, &f.Name, &f.StepsConfiguration)
+	query := `SELECT id, tenant_id, name, steps_configuration, COALESCE(default_locale, 'en'), COALESCE(translations, '{}'), COALESCE(decision_policy, '{}'), COALESCE(review_policy, '{}') FROM flows WHERE tenant_id = $1 AND name = $2`
+	err := r.db.QueryRow(query, tenantID, flowName).Scan(&f.ID, &f.TenantID, &f.Name, &f.StepsConfiguration, &f.DefaultLocale, &f.Translations, &f.DecisionPolicy, &f.ReviewPolicy)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("flow not found")
 	}
@@ -70,8 +143,8 @@ func (r *Repository) GetFlowByName(tenantID string, flowName string) (*domain.Fl
 
 func (r *Repository) GetFlowByID(flowID string) (*domain.Flow, error) {
 	var f domain.Flow
-	query := `SELECT id, tenant_id, name, steps_configuration FROM flows WHERE id = $1`
-	err := r.db.QueryRow(query, flowID).Scan(&f.ID, &f.TenantID, &f.Name, &f.StepsConfiguration)
+	query := `SELECT id, tenant_id, name, COALESCE(description, ''), steps_configuration, COALESCE(default_locale, 'en'), COALESCE(translations, '{}'), COALESCE(decision_policy, '{}'), COALESCE(review_policy, '{}') FROM flows WHERE id = $1`
+	err := r.db.QueryRow(query, flowID).Scan(&f.ID, &f.TenantID, &f.Name, &f.Description, &f.StepsConfiguration, &f.DefaultLocale, &f.Translations, &f.DecisionPolicy, &f.ReviewPolicy)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("flow not found")
 	}
@@ -83,10 +156,10 @@ func (r *Repository) GetFlowByID(flowID string) (*domain.Flow, error) {
 
 func (r *Repository) CreateFlow(f *domain.Flow) error {
 	query := `
-		INSERT INTO flows (id, tenant_id, name, description, steps_configuration, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO flows (id, tenant_id, name, description, steps_configuration, default_locale, translations, decision_policy, review_policy, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
-	_, err := r.db.Exec(query, f.ID, f.TenantID, f.Name, f.Description, f.StepsConfiguration, f.CreatedAt, f.UpdatedAt)
+	_, err := r.db.Exec(query, f.ID, f.TenantID, f.Name, f.Description, f.StepsConfiguration, f.DefaultLocale, f.Translations, f.DecisionPolicy, f.ReviewPolicy, f.CreatedAt, f.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create flow: %w", err)
 	}
@@ -96,10 +169,10 @@ func (r *Repository) CreateFlow(f *domain.Flow) error {
 func (r *Repository) UpdateFlow(f *domain.Flow) error {
 	query := `
 		UPDATE flows 
-		SET name = $1, description = $2, steps_configuration = $3, updated_at = $4
-		WHERE id = $5
+		SET name = $1, description = $2, steps_configuration = $3, default_locale = $4, translations = $5, decision_policy = $6, review_policy = $7, updated_at = $8
+		WHERE id = $9
 	`
-	_, err := r.db.Exec(query, f.Name, f.Description, f.StepsConfiguration, f.UpdatedAt, f.ID)
+	_, err := r.db.Exec(query, f.Name, f.Description, f.StepsConfiguration, f.DefaultLocale, f.Translations, f.DecisionPolicy, f.ReviewPolicy, f.UpdatedAt, f.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update flow: %w", err)
 	}
@@ -107,7 +180,7 @@ func (r *Repository) UpdateFlow(f *domain.Flow) error {
 }
 
 func (r *Repository) ListStepTemplates(tenantID string) ([]domain.StepTemplate, error) {
-	query := `SELECT id, tenant_id, slug, name, description, strategy, base_config, is_system FROM step_templates WHERE tenant_id = $1 OR is_system = TRUE`
+	query := `SELECT id, tenant_id, slug, name, description, strategy, base_config, COALESCE(translations, '{}'), is_system FROM step_templates WHERE tenant_id = $1 OR is_system = TRUE`
 	rows, err := r.db.Query(query, tenantID)
 	if err != nil {
 		return nil, err
@@ -117,7 +190,7 @@ func (r *Repository) ListStepTemplates(tenantID string) ([]domain.StepTemplate,
 	var templates []domain.StepTemplate
 	for rows.Next() {
 		var t domain.StepTemplate
-		if err := rows.Scan(&t.ID, &t.TenantID, &t.Slug, &t.Name, &t.Description, &t.Strategy, &t.BaseConfig, &t.IsSystem); err != nil {
+		if err := rows.Scan(&t.ID, &t.TenantID, &t.Slug, &t.Name, &t.Description, &t.Strategy, &t.BaseConfig, &t.Translations, &t.IsSystem); err != nil {
 			return nil, err
 		}
 		templates = append(templates, t)
@@ -126,9 +199,9 @@ func (r *Repository) ListStepTemplates(tenantID string) ([]domain.StepTemplate,
 }
 
 func (r *Repository) GetStepTemplateByID(id string, tenantID string) (*domain.StepTemplate, error) {
-	query := `SELECT id, tenant_id, slug, name, description, strategy, base_config, is_system FROM step_templates WHERE id = $1 AND (tenant_id = $2 OR is_system = TRUE)`
+	query := `SELECT id, tenant_id, slug, name, description, strategy, base_config, COALESCE(translations, '{}'), is_system FROM step_templates WHERE id = $1 AND (tenant_id = $2 OR is_system = TRUE)`
 	var t domain.StepTemplate
-	err := r.db.QueryRow(query, id, tenantID).Scan(&t.ID, &t.TenantID, &t.Slug, &t.Name, &t.Description, &t.Strategy, &t.BaseConfig, &t.IsSystem)
+	err := r.db.QueryRow(query, id, tenantID).Scan(&t.ID, &t.TenantID, &t.Slug, &t.Name, &t.Description, &t.Strategy, &t.BaseConfig, &t.Translations, &t.IsSystem)
 	if err != nil {
 		return nil, err
 	}
@@ -136,15 +209,15 @@ func (r *Repository) GetStepTemplateByID(id string, tenantID string) (*domain.St
 }
 
 func (r *Repository) CreateStepTemplate(t *domain.StepTemplate) error {
-	query := `INSERT INTO step_templates (id, tenant_id, slug, name, description, strategy, base_config, is_system, created_at, updated_at)
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
-	_, err := r.db.Exec(query, t.ID, t.TenantID, t.Slug, t.Name, t.Description, t.Strategy, t.BaseConfig, t.IsSystem, t.CreatedAt, t.UpdatedAt)
+	query := `INSERT INTO step_templates (id, tenant_id, slug, name, description, strategy, base_config, translations, is_system, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.Exec(query, t.ID, t.TenantID, t.Slug, t.Name, t.Description, t.Strategy, t.BaseConfig, t.Translations, t.IsSystem, t.CreatedAt, t.UpdatedAt)
 	return err
 }
 
 func (r *Repository) UpdateStepTemplate(t *domain.StepTemplate) error {
-	query := `UPDATE step_templates SET name=$1, description=$2, base_config=$3, updated_at=$4 WHERE id=$5 AND tenant_id=$6`
-	_, err := r.db.Exec(query, t.Name, t.Description, t.BaseConfig, t.UpdatedAt, t.ID, t.TenantID)
+	query := `UPDATE step_templates SET name=$1, description=$2, base_config=$3, translations=$4, updated_at=$5 WHERE id=$6 AND tenant_id=$7`
+	_, err := r.db.Exec(query, t.Name, t.Description, t.BaseConfig, t.Translations, t.UpdatedAt, t.ID, t.TenantID)
 	return err
 }
 
@@ -170,7 +243,7 @@ func (r *Repository) DeleteFlow(id string) error {
 }
 
 func (r *Repository) ListFlows(tenantID string) ([]domain.Flow, error) {
-	query := `SELECT id, tenant_id, name, description, steps_configuration, created_at, updated_at FROM flows WHERE tenant_id = $1 ORDER BY created_at DESC`
+	query := `SELECT id, tenant_id, name, description, steps_configuration, COALESCE(default_locale, 'en'), COALESCE(translations, '{}'), COALESCE(decision_policy, '{}'), COALESCE(review_policy, '{}'), created_at, updated_at FROM flows WHERE tenant_id = $1 ORDER BY created_at DESC`
 	rows, err := r.db.Query(query, tenantID)
 	if err != nil {
 		return nil, err
@@ -180,7 +253,7 @@ func (r *Repository) ListFlows(tenantID string) ([]domain.Flow, error) {
 	var flows []domain.Flow
 	for rows.Next() {
 		var f domain.Flow
-		if err := rows.Scan(&f.ID, &f.TenantID, &f.Name, &f.Description, &f.StepsConfiguration, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.TenantID, &f.Name, &f.Description, &f.StepsConfiguration, &f.DefaultLocale, &f.Translations, &f.DecisionPolicy, &f.ReviewPolicy, &f.CreatedAt, &f.UpdatedAt); err != nil {
 			return nil, err
 		}
 		flows = append(flows, f)
@@ -190,20 +263,24 @@ func (r *Repository) ListFlows(tenantID string) ([]domain.Flow, error) {
 
 func (r *Repository) CreateSession(s *domain.Session) error {
 	query := `
-		INSERT INTO sessions (token, flow_id, user_reference, expires_at, status, collected_data)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO sessions (id, token, flow_id, user_reference, expires_at, status, collected_data, results, metadata, locale, success_url, failure_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
-	_, err := r.db.Exec(query, s.Token, s.FlowID, s.UserReference, s.ExpiresAt, s.Status, s.CollectedData)
+	if s.Results == nil {
+		s.Results = domain.JSONB{}
+	}
+	_, err := r.db.Exec(query, s.ID, s.Token, s.FlowID, s.UserReference, s.ExpiresAt, s.Status, s.CollectedData, s.Results, s.Metadata, s.Locale, s.SuccessURL, s.FailureURL)
 	if err != nil {
 		return fmt.Errorf("failed to insert session: %w", err)
 	}
 	return nil
 }
 
-func (r *Repository) GetSessionByToken(token string) (*domain.Session, error) {
+const sessionColumns = `id, token, flow_id, user_reference, current_step_index, status, collected_data, COALESCE(results, '{}'), COALESCE(metadata, '{}'), COALESCE(locale, ''), COALESCE(success_url, ''), COALESCE(failure_url, ''), expires_at, created_at, updated_at, COALESCE(device_secret_hash, ''), COALESCE(watcher_secret_hash, '')`
+
+func scanSession(row *sql.Row) (*domain.Session, error) {
 	var s domain.Session
-	query := `SELECT token, flow_id, user_reference, current_step_index, status, collected_data, expires_at FROM sessions WHERE token = $1`
-	err := r.db.QueryRow(query, token).Scan(&s.Token, &s.FlowID, &s.UserReference, &s.CurrentStepIndex, &s.Status, &s.CollectedData, &s.ExpiresAt)
+	err := row.Scan(&s.ID, &s.Token, &s.FlowID, &s.UserReference, &s.CurrentStepIndex, &s.Status, &s.CollectedData, &s.Results, &s.Metadata, &s.Locale, &s.SuccessURL, &s.FailureURL, &s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt, &s.DeviceSecretHash, &s.WatcherSecretHash)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("session not found")
 	}
@@ -213,17 +290,28 @@ func (r *Repository) GetSessionByToken(token string) (*domain.Session, error) {
 	return &s, nil
 }
 
-func (r *Repository) UpdateSession(s *domain.Session) error {
+func (r *Repository) GetSessionByToken(token string) (*domain.Session, error) {
+	return scanSession(r.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE token = $1`, token))
+}
+
+func (r *Repository) GetSessionByID(id string) (*domain.Session, error) {
+	return scanSession(r.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id))
+}
+
+// UpdateSession saves the progress of the Secure Flow. It returns false when
+// the session no longer accepts submissions (decided or expired meanwhile).
+func (r *Repository) UpdateSession(s *domain.Session) (bool, error) {
 	query := `
         UPDATE sessions 
-        SET current_step_index = $1, collected_data = $2, status = $3, updated_at = NOW()
-        WHERE token = $4
+        SET current_step_index = $1, collected_data = $2, status = $3, results = $5, updated_at = NOW(),
+            review_started_at = CASE WHEN $3::session_status = 'REVIEW_REQUIRED' THEN COALESCE(review_started_at, NOW()) ELSE review_started_at END
+        WHERE token = $4 AND status IN ('PENDING', 'IN_PROGRESS') AND expires_at > NOW()
     `
-	_, err := r.db.Exec(query, s.CurrentStepIndex, s.CollectedData, s.Status, s.Token)
+	ok, err := r.execAffected(query, s.CurrentStepIndex, s.CollectedData, s.Status, s.Token, s.Results)
 	if err != nil {
-		return fmt.Errorf("failed to update session: %w", err)
+		return false, fmt.Errorf("failed to update session: %w", err)
 	}
-	return nil
+	return ok, nil
 }
 
 func (r *Repository) GetTenantUserByEmail(email string) (*domain.TenantUser, error) {
@@ -301,7 +389,7 @@ func (r *Repository) ListSessions(tenantID string, limit int, search string) ([]
 	query += fmt.Sprintf(`
 		ORDER BY 
 			CASE 
-				WHEN status = 'REVIEW_REQUIRED' THEN 0 
+				WHEN status IN ('REVIEW_REQUIRED', 'PENDING_CONFIRMATION') THEN 0 
 				WHEN status = 'PENDING' THEN 1 
 				ELSE 2 
 			END, 
@@ -329,6 +417,260 @@ func (r *Repository) ListSessions(tenantID string, limit int, search string) ([]
 	return sessions, nil
 }
 
+// ListFinishedSessions returns the latest sessions of a flow that completed all
+// their steps, whatever was decided about them
+func (r *Repository) ListFinishedSessions(flowID string, limit int) ([]domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE flow_id = $1 AND status IN ('REVIEW_REQUIRED', 'APPROVED', 'REJECTED') ORDER BY created_at DESC LIMIT $2`
+	rows, err := r.db.Query(query, flowID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		var s domain.Session
+		if err := rows.Scan(&s.ID, &s.Token, &s.FlowID, &s.UserReference, &s.CurrentStepIndex, &s.Status, &s.CollectedData, &s.Results, &s.Metadata, &s.Locale, &s.SuccessURL, &s.FailureURL, &s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt, &s.DeviceSecretHash, &s.WatcherSecretHash); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// ListReviewQueue returns the tenant sessions waiting for a reviewer (first
+// decision or four-eyes confirmation), oldest first.
+// assignedTo filters by reviewer: "" for all, "unassigned" or a tenant user ID.
+func (r *Repository) ListReviewQueue(tenantID string, assignedTo string, limit int) ([]domain.ReviewQueueItem, error) {
+	query := `
+		SELECT s.token, s.flow_id, f.name, COALESCE(s.user_reference, ''), s.status, s.created_at,
+			COALESCE(s.review_started_at, s.updated_at), s.assigned_to, s.locked_by, s.locked_until,
+			s.proposed_status, s.proposed_by, s.proposed_at
+		FROM sessions s JOIN flows f ON f.id = s.flow_id
+		WHERE f.tenant_id = $1 AND s.status IN ('REVIEW_REQUIRED', 'PENDING_CONFIRMATION')
+	`
+	args := []interface{}{tenantID}
+	switch assignedTo {
+	case "":
+	case "unassigned":
+		query += ` AND s.assigned_to IS NULL`
+	default:
+		query += ` AND s.assigned_to = $2`
+		args = append(args, assignedTo)
+	}
+	query += fmt.Sprintf(` ORDER BY COALESCE(s.review_started_at, s.updated_at) ASC LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.ReviewQueueItem{}
+	for rows.Next() {
+		var item domain.ReviewQueueItem
+		var startedAt time.Time
+		if err := rows.Scan(&item.Token, &item.FlowID, &item.FlowName, &item.UserReference, &item.Status, &item.CreatedAt, &startedAt, &item.AssignedTo, &item.LockedBy, &item.LockedUntil, &item.ProposedStatus, &item.ProposedBy, &item.ProposedAt); err != nil {
+			return nil, err
+		}
+		item.ReviewStartedAt = &startedAt
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *Repository) GetReviewState(token string) (*domain.ReviewState, error) {
+	var state domain.ReviewState
+	query := `SELECT review_started_at, assigned_to, locked_by, locked_until, reviewed_by, reviewed_at, proposed_status, proposed_by, proposed_at FROM sessions WHERE token = $1`
+	err := r.db.QueryRow(query, token).Scan(&state.ReviewStartedAt, &state.AssignedTo, &state.LockedBy, &state.LockedUntil, &state.ReviewedBy, &state.ReviewedAt, &state.ProposedStatus, &state.ProposedBy, &state.ProposedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ClaimSession locks a session awaiting a reviewer to a reviewer until the given
+// time. It fails (false) when another reviewer holds an unexpired lock; the
+// reviewer holding the lock can claim again to extend it.
+func (r *Repository) ClaimSession(token string, userID string, until time.Time) (bool, error) {
+	query := `
+		UPDATE sessions SET locked_by = $1, locked_until = $2
+		WHERE token = $3 AND status IN ('REVIEW_REQUIRED', 'PENDING_CONFIRMATION')
+			AND (locked_by IS NULL OR locked_by = $1 OR locked_until < NOW())
+	`
+	return r.execAffected(query, userID, until, token)
+}
+
+// ReleaseSession removes the reviewer's lock. Releasing a lock held by someone
+// else is a no-op (false).
+func (r *Repository) ReleaseSession(token string, userID string) (bool, error) {
+	return r.execAffected(`UPDATE sessions SET locked_by = NULL, locked_until = NULL WHERE token = $1 AND locked_by = $2`, token, userID)
+}
+
+// AssignSession sets the reviewer responsible for a session, nil unassigns it.
+// It fails (false) if the session isn't awaiting review.
+func (r *Repository) AssignSession(token string, userID *string) (bool, error) {
+	query := `
+		UPDATE sessions SET assigned_to = $1
+		WHERE token = $2 AND status IN ('REVIEW_REQUIRED', 'PENDING_CONFIRMATION')
+	`
+	return r.execAffected(query, userID, token)
+}
+
+// DecideReview applies a reviewer decision to a session awaiting review and
+// releases its lock. It fails (false) if the session left review meanwhile or
+// the reviewer doesn't hold a live lock on it (see ClaimSession).
+func (r *Repository) DecideReview(token string, status domain.SessionStatus, reviewerID string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET status = $1, reviewed_by = $2, reviewed_at = NOW(), locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE token = $3 AND status = 'REVIEW_REQUIRED'
+			AND locked_by = $2 AND locked_until > NOW()
+	`
+	return r.execAffected(query, status, reviewerID, token)
+}
+
+// ProposeDecision records the first decision of a four-eyes review and moves
+// the session to PENDING_CONFIRMATION. Same conditions as DecideReview.
+func (r *Repository) ProposeDecision(token string, status domain.SessionStatus, reviewerID string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET status = 'PENDING_CONFIRMATION', proposed_status = $1, proposed_by = $2, proposed_at = NOW(),
+			locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE token = $3 AND status = 'REVIEW_REQUIRED'
+			AND locked_by = $2 AND locked_until > NOW()
+	`
+	return r.execAffected(query, status, reviewerID, token)
+}
+
+// ConfirmDecision applies the second decision of a four-eyes review, which may
+// confirm or overturn the proposed one. The proposer can't confirm their own decision.
+func (r *Repository) ConfirmDecision(token string, status domain.SessionStatus, reviewerID string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET status = $1, reviewed_by = $2, reviewed_at = NOW(), locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE token = $3 AND status = 'PENDING_CONFIRMATION' AND proposed_by <> $2
+			AND locked_by = $2 AND locked_until > NOW()
+	`
+	return r.execAffected(query, status, reviewerID, token)
+}
+
+// OverrideDecision replaces a final decision. It fails (false) if the status
+// changed meanwhile.
+func (r *Repository) OverrideDecision(token string, from, to domain.SessionStatus, reviewerID string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET status = $1, reviewed_by = $2, reviewed_at = NOW(), updated_at = NOW()
+		WHERE token = $3 AND status = $4
+	`
+	return r.execAffected(query, to, reviewerID, token, from)
+}
+
+// ReopenSession sends a session awaiting review back to the user under a new
+// token. The old token stops working, the new link may be opened on any device
+// and the next review starts a new SLA.
+// Same conditions as DecideReview.
+func (r *Repository) ReopenSession(token, newToken string, s *domain.Session, reviewerID string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET token = $1, status = $2, current_step_index = $3, collected_data = $4, results = $8, expires_at = $5,
+			reviewed_by = $6, reviewed_at = NOW(), review_started_at = NULL, locked_by = NULL, locked_until = NULL,
+			device_secret_hash = NULL, device_bound_at = NULL, handoff_code_hash = NULL, handoff_expires_at = NULL, watcher_secret_hash = NULL, updated_at = NOW()
+		WHERE token = $7 AND status = 'REVIEW_REQUIRED'
+			AND locked_by = $6 AND locked_until > NOW()
+	`
+	return r.execAffected(query, newToken, s.Status, s.CurrentStepIndex, s.CollectedData, s.ExpiresAt, reviewerID, token, s.Results)
+}
+
+func (r *Repository) execAffected(query string, args ...interface{}) (bool, error) {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *Repository) GetTenantUserByID(id string) (*domain.TenantUser, error) {
+	query := `SELECT id, tenant_id, email, password_hash, role, created_at, updated_at FROM tenant_users WHERE id = $1`
+	var u domain.TenantUser
+	err := r.db.QueryRow(query, id).Scan(&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *Repository) AddSessionEvent(e *domain.SessionEvent) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	if e.Kind == "" {
+		e.Kind = domain.EventStatusChange
+	}
+	query := `
+		INSERT INTO session_events (id, session_id, kind, from_status, to_status, actor_type, actor_id, reason_code, notes, proposed_status, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11)
+	`
+	_, err := r.db.Exec(query, e.ID, e.SessionID, e.Kind, string(e.FromStatus), string(e.ToStatus), e.ActorType, e.ActorID, e.ReasonCode, e.Notes, string(e.ProposedStatus), e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert session event: %w", err)
+	}
+	return nil
+}
+
+// ListSessionEvents returns the audit trail of a session, oldest first
+func (r *Repository) ListSessionEvents(sessionID string) ([]domain.SessionEvent, error) {
+	query := `
+		SELECT id, session_id, kind, COALESCE(from_status, ''), to_status, actor_type, COALESCE(actor_id, ''), COALESCE(reason_code, ''), COALESCE(notes, ''), COALESCE(proposed_status, ''), created_at
+		FROM session_events WHERE session_id = $1 ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []domain.SessionEvent{}
+	for rows.Next() {
+		var e domain.SessionEvent
+		if err := rows.Scan(&e.ID, &e.SessionID, &e.Kind, &e.FromStatus, &e.ToStatus, &e.ActorType, &e.ActorID, &e.ReasonCode, &e.Notes, &e.ProposedStatus, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *Repository) GetTenantReviewReasons(tenantID string) (domain.ReasonCodes, error) {
+	var codes domain.ReasonCodes
+	err := r.db.QueryRow(`SELECT COALESCE(review_reasons, '[]') FROM tenants WHERE id = $1`, tenantID).Scan(&codes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (r *Repository) UpdateTenantReviewReasons(tenantID string, codes domain.ReasonCodes) error {
+	_, err := r.db.Exec(`UPDATE tenants SET review_reasons = $1, updated_at = NOW() WHERE id = $2`, codes, tenantID)
+	return err
+}
+
+func (r *Repository) UpdateTenantRateLimits(tenantID string, policy domain.RateLimitPolicy) error {
+	_, err := r.db.Exec(`UPDATE tenants SET rate_limits = $1, updated_at = NOW() WHERE id = $2`, policy, tenantID)
+	return err
+}
+
 func (r *Repository) UpdateSessionStatus(token string, status domain.SessionStatus) error {
 	query := `UPDATE sessions SET status = $1, updated_at = NOW() WHERE token = $2`
 	_, err := r.db.Exec(query, status, token)
@@ -343,10 +685,10 @@ func (r *Repository) RegisterTenant(t *domain.Tenant, u *domain.TenantUser) erro
 
 	// 1. Insert Tenant
 	queryTenant := `
-		INSERT INTO tenants (id, name, api_key_hash, api_key_last_4, webhook_url, branding_config, credits_balance, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		INSERT INTO tenants (id, name, api_key_hash, api_key_last_4, webhook_url, branding_config, credits_balance, allowed_redirect_domains, signing_secret, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
 	`
-	_, err = tx.Exec(queryTenant, t.ID, t.Name, t.APIKeyHash, t.APIKeyLast4, t.WebhookURL, t.BrandingConfig, t.CreditsBalance)
+	_, err = tx.Exec(queryTenant, t.ID, t.Name, t.APIKeyHash, t.APIKeyLast4, t.WebhookURL, t.BrandingConfig, t.CreditsBalance, pq.Array(t.AllowedRedirectDomains), t.SigningSecret)
 	if err != nil {
 		return fmt.Errorf("failed to insert tenant: %w", err)
 	}
@@ -363,3 +705,133 @@ func (r *Repository) RegisterTenant(t *domain.Tenant, u *domain.TenantUser) erro
 
 	return tx.Commit()
 }
+
+// ReplaceSessionFingerprints stores the current fingerprints of a session
+func (r *Repository) ReplaceSessionFingerprints(tenantID, sessionID string, fingerprints []domain.Fingerprint) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM session_fingerprints WHERE session_id = $1`, sessionID); err != nil {
+		return err
+	}
+	for _, f := range fingerprints {
+		query := `
+			INSERT INTO session_fingerprints (session_id, tenant_id, kind, value, phash)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.Exec(query, sessionID, tenantID, f.Kind, f.Value, f.PHash); err != nil {
+			return fmt.Errorf("failed to insert fingerprint: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// FindDuplicateSessions returns the other sessions of the tenant sharing a
+// fingerprint with the session: same identity hash, or images whose perceptual
+// hashes differ in at most maxDistance bits. Closest and newest first.
+func (r *Repository) FindDuplicateSessions(sessionID string, maxDistance int) ([]domain.DuplicateMatch, error) {
+	query := `
+		SELECT kind, id, token, user_reference, status, created_at, distance FROM (
+			SELECT DISTINCT ON (mine.kind, s.id) mine.kind, s.id, s.token, s.user_reference, s.status, s.created_at,
+				CASE WHEN other.value = mine.value THEN 0
+					ELSE length(replace(((other.phash # mine.phash)::bit(64))::text, '0', '')) END AS distance
+			FROM session_fingerprints mine
+			JOIN session_fingerprints other
+				ON other.tenant_id = mine.tenant_id AND other.kind = mine.kind AND other.session_id <> mine.session_id
+			JOIN sessions s ON s.id = other.session_id
+			WHERE mine.session_id = $1
+				AND (other.value = mine.value
+					OR length(replace(((other.phash # mine.phash)::bit(64))::text, '0', '')) <= $2)
+			ORDER BY mine.kind, s.id, distance
+		) matches
+		ORDER BY distance, created_at DESC
+		LIMIT 50
+	`
+	rows, err := r.db.Query(query, sessionID, maxDistance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate sessions: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []domain.DuplicateMatch{}
+	for rows.Next() {
+		var m domain.DuplicateMatch
+		if err := rows.Scan(&m.Kind, &m.SessionID, &m.Token, &m.UserReference, &m.Status, &m.CreatedAt, &m.Distance); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+func (r *Repository) AddSessionAccess(a *domain.SessionAccess) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	query := `
+		INSERT INTO session_accesses (id, session_id, endpoint, ip, user_agent, accept_language, device_id, country, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9)
+	`
+	_, err := r.db.Exec(query, a.ID, a.SessionID, a.Endpoint, a.IP, a.UserAgent, a.AcceptLanguage, a.DeviceID, a.Country, a.CreatedAt)
+	return err
+}
+
+// ListSessionAccesses returns the Secure Flow calls of a session, oldest first
+func (r *Repository) ListSessionAccesses(sessionID string) ([]domain.SessionAccess, error) {
+	query := `
+		SELECT id, session_id, endpoint, ip, COALESCE(user_agent, ''), COALESCE(accept_language, ''), COALESCE(device_id, ''), COALESCE(country, ''), created_at
+		FROM session_accesses WHERE session_id = $1 ORDER BY created_at
+	`
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session accesses: %w", err)
+	}
+	defer rows.Close()
+
+	accesses := []domain.SessionAccess{}
+	for rows.Next() {
+		var a domain.SessionAccess
+		if err := rows.Scan(&a.ID, &a.SessionID, &a.Endpoint, &a.IP, &a.UserAgent, &a.AcceptLanguage, &a.DeviceID, &a.Country, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, a)
+	}
+	return accesses, rows.Err()
+}
+
+// BindSessionDevice binds a session to the first device that opens it. It fails
+// (false) when another device got there first.
+func (r *Repository) BindSessionDevice(sessionID, secretHash string) (bool, error) {
+	query := `
+		UPDATE sessions SET device_secret_hash = $1, device_bound_at = NOW()
+		WHERE id = $2 AND device_secret_hash IS NULL
+	`
+	return r.execAffected(query, secretHash, sessionID)
+}
+
+// CreateHandoff stores a one-time code to move the session to another device,
+// replacing any previous one
+func (r *Repository) CreateHandoff(sessionID, codeHash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE sessions SET handoff_code_hash = $1, handoff_expires_at = $2 WHERE id = $3`, codeHash, expiresAt, sessionID)
+	return err
+}
+
+// RedeemHandoff binds the session to a new device if the code is valid. The
+// code is consumed and the previous device becomes the watcher: it can only
+// follow the progress.
+func (r *Repository) RedeemHandoff(sessionID, codeHash, secretHash string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET watcher_secret_hash = device_secret_hash, device_secret_hash = $1, device_bound_at = NOW(),
+			handoff_code_hash = NULL, handoff_expires_at = NULL
+		WHERE id = $2 AND handoff_code_hash = $3 AND handoff_expires_at > NOW()
+	`
+	return r.execAffected(query, secretHash, sessionID, codeHash)
+}
@@ -0,0 +1,445 @@
+// Package pdf417 reads PDF417 barcodes, like the ones printed on the back of
+// driver's licenses and ID cards, from images.
+//
+// It targets scans and reasonably straight photos: every scanline is read on
+// its own, codewords are voted per row/column using the row indicators and
+// Reed-Solomon error correction fixes what was misread. The image is tried in
+// the four orientations.
+package pdf417
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+)
+
+var ErrNotFound = errors.New("pdf417: no barcode found")
+
+// Widths in modules of the start and stop patterns, bar first
+var (
+	startModules = []int{8, 1, 1, 1, 1, 1, 1, 3}
+	stopModules  = []int{7, 1, 1, 3, 1, 1, 1, 2, 1}
+)
+
+const (
+	codewordModules = 17
+	maxRows         = 90
+	maxCols         = 30
+)
+
+type codeword struct {
+	cluster int // 0, 1 or 2 for clusters 0, 3 and 6
+	value   int // -1 when the pattern is not a valid codeword
+}
+
+var patternIndex = func() map[uint32]codeword {
+	index := make(map[uint32]codeword, 3*929)
+	for c := range clusterPatterns {
+		for v, p := range clusterPatterns[c] {
+			index[p] = codeword{cluster: c, value: v}
+		}
+	}
+	return index
+}()
+
+// Decode finds a PDF417 symbol in the image and returns its data
+func Decode(img image.Image) ([]byte, error) {
+	b := newBitImage(img)
+	for orientation := 0; orientation < 4; orientation++ {
+		if data, err := b.decode(orientation); err == nil {
+			return data, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// bitImage is the binarized image (true = dark)
+type bitImage struct {
+	w, h int
+	dark []bool
+}
+
+func newBitImage(img image.Image) *bitImage {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	gray := make([]uint8, w*h)
+	var histogram [256]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			l := uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+			gray[y*w+x] = l
+			histogram[l]++
+		}
+	}
+
+	threshold := otsu(histogram, w*h)
+	dark := make([]bool, w*h)
+	for i, l := range gray {
+		dark[i] = l < threshold
+	}
+	return &bitImage{w: w, h: h, dark: dark}
+}
+
+// otsu picks the threshold that best separates dark and light pixels
+func otsu(histogram [256]int, total int) uint8 {
+	var sum float64
+	for i, n := range histogram {
+		sum += float64(i * n)
+	}
+	var sumB, best float64
+	var weightB int
+	threshold := 128
+	for i, n := range histogram {
+		weightB += n
+		if weightB == 0 {
+			continue
+		}
+		weightF := total - weightB
+		if weightF == 0 {
+			break
+		}
+		sumB += float64(i * n)
+		meanB := sumB / float64(weightB)
+		meanF := (sum - sumB) / float64(weightF)
+		between := float64(weightB) * float64(weightF) * (meanB - meanF) * (meanB - meanF)
+		if between > best {
+			best = between
+			threshold = i + 1
+		}
+	}
+	return uint8(min(threshold, 255))
+}
+
+// line returns scanline k for an orientation: rows left to right, rows right
+// to left, columns top to bottom and columns bottom to top.
+func (b *bitImage) line(orientation, k int) []bool {
+	if orientation < 2 {
+		line := make([]bool, b.w)
+		for x := 0; x < b.w; x++ {
+			line[x] = b.dark[k*b.w+x]
+		}
+		if orientation == 1 {
+			reverse(line)
+		}
+		return line
+	}
+	line := make([]bool, b.h)
+	for y := 0; y < b.h; y++ {
+		line[y] = b.dark[y*b.w+k]
+	}
+	if orientation == 3 {
+		reverse(line)
+	}
+	return line
+}
+
+func (b *bitImage) lines(orientation int) int {
+	if orientation < 2 {
+		return b.h
+	}
+	return b.w
+}
+
+func reverse(s []bool) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// symbolVotes accumulates what every scanline read
+type symbolVotes struct {
+	codewords map[[2]int]map[int]int // (row, column) -> value -> votes
+	rowsHigh  map[int]int            // (rows-1)/3
+	rowsLowEC map[int]int            // ecLevel*3 + (rows-1)%3
+	cols      map[int]int            // cols-1
+}
+
+func (b *bitImage) decode(orientation int) ([]byte, error) {
+	votes := &symbolVotes{
+		codewords: map[[2]int]map[int]int{},
+		rowsHigh:  map[int]int{},
+		rowsLowEC: map[int]int{},
+		cols:      map[int]int{},
+	}
+	found := false
+	for k := 0; k < b.lines(orientation); k++ {
+		if read, stopped := scanLine(b.line(orientation, k)); len(read) > 1 {
+			found = votes.add(read, stopped) || found
+		}
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return votes.decode()
+}
+
+// scanLine reads the codewords of one scanline, left row indicator first
+func scanLine(line []bool) ([]codeword, bool) {
+	runs, firstDark := runLengths(line)
+	for i := 0; i+len(startModules) <= len(runs); i++ {
+		if (i%2 == 0) != firstDark {
+			continue // Patterns start with a bar
+		}
+		unit, ok := matchModules(runs[i:i+len(startModules)], startModules)
+		if !ok {
+			continue
+		}
+		if read, stopped := readCodewords(runs[i+len(startModules):], unit); len(read) > 1 {
+			return read, stopped
+		}
+	}
+	return nil, false
+}
+
+func readCodewords(runs []int, unit float64) ([]codeword, bool) {
+	var read []codeword
+	for j := 0; ; j += 8 {
+		if j+len(stopModules) <= len(runs) {
+			if _, ok := matchModules(runs[j:j+len(stopModules)], stopModules); ok {
+				return read, true
+			}
+		}
+		if j+8 > len(runs) {
+			return read, false
+		}
+		group := runs[j : j+8]
+		total := float64(sum(group))
+		if total < 12*unit || total > 22*unit {
+			return read, false // Left the symbol
+		}
+		cw, ok := patternIndex[toPattern(group)]
+		if !ok {
+			cw = codeword{value: -1}
+		}
+		read = append(read, cw)
+		unit = (3*unit + total/codewordModules) / 4 // Follow perspective drift
+	}
+}
+
+func runLengths(line []bool) ([]int, bool) {
+	if len(line) == 0 {
+		return nil, false
+	}
+	var runs []int
+	current, count := line[0], 0
+	for _, d := range line {
+		if d == current {
+			count++
+			continue
+		}
+		runs = append(runs, count)
+		current, count = d, 1
+	}
+	return append(runs, count), line[0]
+}
+
+func sum(s []int) int {
+	total := 0
+	for _, v := range s {
+		total += v
+	}
+	return total
+}
+
+// matchModules checks run widths against a pattern and returns the module width
+func matchModules(runs []int, modules []int) (float64, bool) {
+	unit := float64(sum(runs)) / float64(sum(modules))
+	if unit < 1 {
+		return 0, false
+	}
+	for i, m := range modules {
+		if math.Abs(float64(runs[i])/unit-float64(m)) > 0.8+0.1*float64(m) {
+			return 0, false
+		}
+	}
+	return unit, true
+}
+
+// toPattern converts the 8 runs of a codeword to its 17 bit bar/space pattern
+func toPattern(group []int) uint32 {
+	total := float64(sum(group))
+	var modules [8]int
+	var exact [8]float64
+	count := 0
+	for k, w := range group {
+		exact[k] = float64(w) * codewordModules / total
+		modules[k] = min(max(int(math.Round(exact[k])), 1), 6)
+		count += modules[k]
+	}
+
+	// Fix rounding so the widths add up to 17 modules
+	for count != codewordModules {
+		best, bestErr := -1, -math.MaxFloat64
+		for k := range modules {
+			var err float64
+			if count > codewordModules && modules[k] > 1 {
+				err = float64(modules[k]) - exact[k]
+			} else if count < codewordModules && modules[k] < 6 {
+				err = exact[k] - float64(modules[k])
+			} else {
+				continue
+			}
+			if err > bestErr {
+				best, bestErr = k, err
+			}
+		}
+		if best < 0 {
+			return 0
+		}
+		if count > codewordModules {
+			modules[best]--
+			count--
+		} else {
+			modules[best]++
+			count++
+		}
+	}
+
+	var pattern uint32
+	for k, m := range modules {
+		for i := 0; i < m; i++ {
+			pattern <<= 1
+			if k%2 == 0 {
+				pattern |= 1
+			}
+		}
+	}
+	return pattern
+}
+
+// add records a scanline. The left row indicator gives the row number; a
+// codeword from another cluster means the (skewed) line crossed into the
+// previous or next row, which is followed along the line.
+func (v *symbolVotes) add(read []codeword, stopped bool) bool {
+	left := read[0]
+	if left.value < 0 {
+		return false
+	}
+	row := 3*(left.value/30) + left.cluster
+	v.addIndicator(left.cluster, left.value%30, false)
+
+	data := read[1:]
+	if stopped {
+		right := read[len(read)-1]
+		if right.value >= 0 {
+			v.addIndicator(right.cluster, right.value%30, true)
+		}
+		v.cols[len(read)-3]++
+		data = read[1 : len(read)-1]
+	}
+
+	for col, cw := range data {
+		if cw.value < 0 {
+			continue
+		}
+		switch (cw.cluster - row%3 + 3) % 3 {
+		case 1:
+			row++
+		case 2:
+			row--
+		}
+		key := [2]int{row, col}
+		if v.codewords[key] == nil {
+			v.codewords[key] = map[int]int{}
+		}
+		v.codewords[key][cw.value]++
+	}
+	return true
+}
+
+// Row indicators carry the symbol size and error correction level, spread
+// over the three clusters (and in a different order on the right side).
+func (v *symbolVotes) addIndicator(cluster, value int, right bool) {
+	if right {
+		cluster = (cluster + 2) % 3
+	}
+	switch cluster {
+	case 0:
+		v.rowsHigh[value]++
+	case 1:
+		v.rowsLowEC[value]++
+	case 2:
+		v.cols[value]++
+	}
+}
+
+// decode tries every row count the indicators voted for, most voted first:
+// some encoders get it off by one in the left indicators, and the two sides
+// can then get close to the same number of votes.
+func (v *symbolVotes) decode() ([]byte, error) {
+	var err error = ErrNotFound
+	for _, rowsHigh := range byVotes(v.rowsHigh) {
+		data, e := v.decodeRows(rowsHigh)
+		if e == nil {
+			return data, nil
+		}
+		err = e
+	}
+	return nil, err
+}
+
+func (v *symbolVotes) decodeRows(rowsHigh int) ([]byte, error) {
+	rowsLowEC, ok1 := mostVoted(v.rowsLowEC)
+	cols, ok2 := mostVoted(v.cols)
+	if !ok1 || !ok2 {
+		return nil, ErrNotFound
+	}
+	rows := 3*rowsHigh + rowsLowEC%3 + 1
+	cols++
+	ecLevel := rowsLowEC / 3
+	if rows < 3 || rows > maxRows || cols < 1 || cols > maxCols || ecLevel > 8 {
+		return nil, ErrNotFound
+	}
+
+	total := rows * cols
+	numEC := 1 << (ecLevel + 1)
+	if total <= numEC {
+		return nil, ErrNotFound
+	}
+	codewords := make([]int, total)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if value, ok := mostVoted(v.codewords[[2]int{r, c}]); ok {
+				codewords[r*cols+c] = value
+			}
+		}
+	}
+
+	if _, err := correctErrors(codewords, numEC); err != nil {
+		return nil, err
+	}
+	if codewords[0] == 0 {
+		codewords[0] = total - numEC
+	}
+	if codewords[0] < 1 || codewords[0] > total-numEC {
+		return nil, errFormat
+	}
+	return decodeData(codewords)
+}
+
+// byVotes returns the voted values, most voted (then lowest) first
+func byVotes(votes map[int]int) []int {
+	values := make([]int, 0, len(votes))
+	for value := range votes {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if votes[values[i]] != votes[values[j]] {
+			return votes[values[i]] > votes[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
+
+func mostVoted(votes map[int]int) (int, bool) {
+	best, bestVotes := 0, 0
+	for value, n := range votes {
+		if n > bestVotes || (n == bestVotes && value < best) {
+			best, bestVotes = value, n
+		}
+	}
+	return best, bestVotes > 0
+}
@@ -0,0 +1,64 @@
+package pdf417
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The images in testdata were encoded with github.com/boombuler/barcode, whose
+// left row indicators are off by one for most row counts, drawn at 3 px per
+// module unless noted with a white quiet zone
+func TestDecodeSamples(t *testing.T) {
+	aamva, err := os.ReadFile(filepath.Join("testdata", "aamva.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"aamva.png", string(aamva)},                                          // Driver's license, security level 3
+		{"aamva_upside_down.png", string(aamva)},                              // Same symbol rotated 180 degrees
+		{"aamva_damaged.png", string(aamva)},                                  // Same symbol smudged over a few codewords
+		{"text_ec5.png", "Hello PDF417 123456789012345 mixed Text & numbers"}, // Text and numeric compaction, security level 5, 2 px per module
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := Decode(readPNG(t, tt.image))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Decode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBlankImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 400, 200))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	if _, err := Decode(img); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Decode = %v, want ErrNotFound", err)
+	}
+}
+
+func readPNG(t *testing.T, name string) image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
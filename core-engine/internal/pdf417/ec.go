@@ -0,0 +1,245 @@
+package pdf417
+
+import "errors"
+
+var errCorrection = errors.New("pdf417: too many errors")
+
+// Reed-Solomon over GF(929) with generator 3, the field used by PDF417
+const fieldSize = 929
+
+var (
+	expTable [fieldSize]int
+	logTable [fieldSize]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < fieldSize; i++ {
+		expTable[i] = x
+		x = (x * 3) % fieldSize
+	}
+	for i := 0; i < fieldSize-1; i++ {
+		logTable[expTable[i]] = i
+	}
+}
+
+func fAdd(a, b int) int { return (a + b) % fieldSize }
+func fSub(a, b int) int { return (fieldSize + a - b) % fieldSize }
+
+func fMul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(logTable[a]+logTable[b])%(fieldSize-1)]
+}
+
+func fInv(a int) int {
+	return expTable[fieldSize-logTable[a]-1]
+}
+
+// poly is a polynomial over GF(929), highest degree coefficient first
+type poly []int
+
+func newPoly(coefficients []int) poly {
+	i := 0
+	for i < len(coefficients)-1 && coefficients[i] == 0 {
+		i++
+	}
+	return poly(coefficients[i:])
+}
+
+func monomial(degree, coefficient int) poly {
+	if coefficient == 0 {
+		return poly{0}
+	}
+	p := make(poly, degree+1)
+	p[0] = coefficient
+	return p
+}
+
+func (p poly) degree() int          { return len(p) - 1 }
+func (p poly) isZero() bool         { return p[0] == 0 }
+func (p poly) coeff(degree int) int { return p[len(p)-1-degree] }
+
+func (p poly) evaluateAt(a int) int {
+	if a == 0 {
+		return p.coeff(0)
+	}
+	result := 0
+	for _, c := range p {
+		result = fAdd(fMul(a, result), c)
+	}
+	return result
+}
+
+func (p poly) add(o poly) poly {
+	if p.isZero() {
+		return o
+	}
+	if o.isZero() {
+		return p
+	}
+	small, large := p, o
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	sum := make([]int, len(large))
+	diff := len(large) - len(small)
+	copy(sum, large[:diff])
+	for i := diff; i < len(large); i++ {
+		sum[i] = fAdd(small[i-diff], large[i])
+	}
+	return newPoly(sum)
+}
+
+func (p poly) negative() poly {
+	n := make([]int, len(p))
+	for i, c := range p {
+		n[i] = fSub(0, c)
+	}
+	return poly(n)
+}
+
+func (p poly) subtract(o poly) poly {
+	if o.isZero() {
+		return p
+	}
+	return p.add(o.negative())
+}
+
+func (p poly) multiply(o poly) poly {
+	if p.isZero() || o.isZero() {
+		return poly{0}
+	}
+	product := make([]int, len(p)+len(o)-1)
+	for i, a := range p {
+		for j, b := range o {
+			product[i+j] = fAdd(product[i+j], fMul(a, b))
+		}
+	}
+	return newPoly(product)
+}
+
+func (p poly) scale(s int) poly {
+	if s == 0 {
+		return poly{0}
+	}
+	out := make([]int, len(p))
+	for i, c := range p {
+		out[i] = fMul(c, s)
+	}
+	return newPoly(out)
+}
+
+func (p poly) multiplyByMonomial(degree, coefficient int) poly {
+	if coefficient == 0 {
+		return poly{0}
+	}
+	out := make([]int, len(p)+degree)
+	for i, c := range p {
+		out[i] = fMul(c, coefficient)
+	}
+	return newPoly(out)
+}
+
+// correctErrors fixes up to numEC/2 wrong codewords in place and returns how
+// many were corrected.
+func correctErrors(received []int, numEC int) (int, error) {
+	p := newPoly(append([]int(nil), received...))
+	syndromes := make([]int, numEC)
+	hasError := false
+	for i := numEC; i > 0; i-- {
+		eval := p.evaluateAt(expTable[i])
+		syndromes[numEC-i] = eval
+		if eval != 0 {
+			hasError = true
+		}
+	}
+	if !hasError {
+		return 0, nil
+	}
+
+	sigma, omega, err := euclidean(monomial(numEC, 1), newPoly(syndromes), numEC)
+	if err != nil {
+		return 0, err
+	}
+	locations, err := errorLocations(sigma)
+	if err != nil {
+		return 0, err
+	}
+	magnitudes := errorMagnitudes(omega, sigma, locations)
+
+	for i, loc := range locations {
+		position := len(received) - 1 - logTable[loc]
+		if position < 0 {
+			return 0, errCorrection
+		}
+		received[position] = fSub(received[position], magnitudes[i])
+	}
+	return len(locations), nil
+}
+
+func euclidean(a, b poly, r int) (poly, poly, error) {
+	if a.degree() < b.degree() {
+		a, b = b, a
+	}
+	rLast, rCur := a, b
+	tLast, tCur := poly{0}, poly{1}
+
+	for rCur.degree() >= r/2 {
+		rLastLast, tLastLast := rLast, tLast
+		rLast, tLast = rCur, tCur
+		if rLast.isZero() {
+			return nil, nil, errCorrection
+		}
+		rCur = rLastLast
+		q := poly{0}
+		dltInverse := fInv(rLast.coeff(rLast.degree()))
+		for rCur.degree() >= rLast.degree() && !rCur.isZero() {
+			degreeDiff := rCur.degree() - rLast.degree()
+			scale := fMul(rCur.coeff(rCur.degree()), dltInverse)
+			q = q.add(monomial(degreeDiff, scale))
+			rCur = rCur.subtract(rLast.multiplyByMonomial(degreeDiff, scale))
+		}
+		tCur = q.multiply(tLast).subtract(tLastLast).negative()
+	}
+
+	sigmaAtZero := tCur.coeff(0)
+	if sigmaAtZero == 0 {
+		return nil, nil, errCorrection
+	}
+	inverse := fInv(sigmaAtZero)
+	return tCur.scale(inverse), rCur.scale(inverse), nil
+}
+
+func errorLocations(sigma poly) ([]int, error) {
+	numErrors := sigma.degree()
+	result := make([]int, 0, numErrors)
+	for i := 1; i < fieldSize && len(result) < numErrors; i++ {
+		if sigma.evaluateAt(i) == 0 {
+			result = append(result, fInv(i))
+		}
+	}
+	if len(result) != numErrors {
+		return nil, errCorrection
+	}
+	return result, nil
+}
+
+func errorMagnitudes(omega, sigma poly, locations []int) []int {
+	deg := sigma.degree()
+	derivative := make([]int, deg)
+	for i := 1; i <= deg; i++ {
+		derivative[deg-i] = fMul(i, sigma.coeff(i))
+	}
+	formalDerivative := newPoly(derivative)
+
+	result := make([]int, len(locations))
+	for i, loc := range locations {
+		xiInverse := fInv(loc)
+		numerator := fSub(0, omega.evaluateAt(xiInverse))
+		denominator := fInv(formalDerivative.evaluateAt(xiInverse))
+		result[i] = fMul(numerator, denominator)
+	}
+	return result
+}
@@ -0,0 +1,305 @@
+package pdf417
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Mode latches and control codewords
+const (
+	textLatch       = 900
+	byteLatch       = 901
+	numericLatch    = 902
+	byteShift       = 913
+	macroTerminator = 922
+	macroOptional   = 923
+	byteLatch6      = 924
+	eciUserDefined  = 925
+	eciGeneral      = 926
+	eciCharset      = 927
+	macroBegin      = 928
+)
+
+var errFormat = errors.New("pdf417: invalid data codewords")
+
+const (
+	mixedChars = "0123456789&\r\t,:#-.$/+%*=^"
+	punctChars = ";<>@[\\]_`~!\r\t,:\n-.$/\"|*()?{}'"
+)
+
+type textSubmode int
+
+const (
+	subAlpha textSubmode = iota
+	subLower
+	subMixed
+	subPunct
+	subAlphaShift
+	subPunctShift
+)
+
+// decodeData turns the data codewords (length descriptor first) into bytes
+func decodeData(codewords []int) ([]byte, error) {
+	var out []byte
+	length := codewords[0]
+	i := 1
+	var err error
+	for i < length {
+		code := codewords[i]
+		i++
+		switch code {
+		case textLatch:
+			i, out = textCompaction(codewords, i, out)
+		case byteLatch, byteLatch6:
+			i, out = byteCompaction(code, codewords, i, out)
+		case byteShift:
+			if i >= length {
+				return nil, errFormat
+			}
+			out = append(out, byte(codewords[i]))
+			i++
+		case numericLatch:
+			i, out, err = numericCompaction(codewords, i, out)
+			if err != nil {
+				return nil, err
+			}
+		case eciCharset, eciUserDefined:
+			i++
+		case eciGeneral:
+			i += 2
+		case macroBegin, macroOptional, macroTerminator:
+			return out, nil // Macro PDF417 control block, nothing after it is data
+		default:
+			if code >= textLatch {
+				return nil, errFormat // Reserved codeword
+			}
+			// Text compaction is the default mode at the start of the symbol
+			i, out = textCompaction(codewords, i-1, out)
+		}
+	}
+	return out, nil
+}
+
+func isLatch(code int) bool {
+	switch code {
+	case byteLatch, byteLatch6, numericLatch, macroBegin, macroOptional, macroTerminator:
+		return true
+	}
+	return false
+}
+
+func textCompaction(codewords []int, i int, out []byte) (int, []byte) {
+	length := codewords[0]
+	var text, shifted []int
+	for i < length {
+		code := codewords[i]
+		i++
+		if code < textLatch {
+			text = append(text, code/30, code%30)
+			shifted = append(shifted, 0, 0)
+			continue
+		}
+		if code == textLatch {
+			text = append(text, textLatch)
+			shifted = append(shifted, 0)
+			continue
+		}
+		if code == byteShift && i < length {
+			text = append(text, byteShift)
+			shifted = append(shifted, codewords[i])
+			i++
+			continue
+		}
+		i--
+		break
+	}
+	return i, decodeText(text, shifted, out)
+}
+
+func decodeText(text, shifted []int, out []byte) []byte {
+	sub, prior := subAlpha, subAlpha
+	for n, v := range text {
+		if v == textLatch {
+			sub = subAlpha
+			continue
+		}
+		if v == byteShift {
+			out = append(out, byte(shifted[n]))
+			continue
+		}
+
+		var ch byte
+		switch sub {
+		case subAlpha:
+			switch {
+			case v < 26:
+				ch = byte('A' + v)
+			case v == 26:
+				ch = ' '
+			case v == 27:
+				sub = subLower
+			case v == 28:
+				sub = subMixed
+			case v == 29:
+				prior, sub = sub, subPunctShift
+			}
+		case subLower:
+			switch {
+			case v < 26:
+				ch = byte('a' + v)
+			case v == 26:
+				ch = ' '
+			case v == 27:
+				prior, sub = sub, subAlphaShift
+			case v == 28:
+				sub = subMixed
+			case v == 29:
+				prior, sub = sub, subPunctShift
+			}
+		case subMixed:
+			switch {
+			case v < 25:
+				ch = mixedChars[v]
+			case v == 25:
+				sub = subPunct
+			case v == 26:
+				ch = ' '
+			case v == 27:
+				sub = subLower
+			case v == 28:
+				sub = subAlpha
+			case v == 29:
+				prior, sub = sub, subPunctShift
+			}
+		case subPunct:
+			if v < 29 {
+				ch = punctChars[v]
+			} else {
+				sub = subAlpha
+			}
+		case subAlphaShift:
+			sub = prior
+			switch {
+			case v < 26:
+				ch = byte('A' + v)
+			case v == 26:
+				ch = ' '
+			}
+		case subPunctShift:
+			sub = prior
+			if v < 29 {
+				ch = punctChars[v]
+			} else {
+				sub = subAlpha
+			}
+		}
+		if ch != 0 {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// byteCompaction decodes groups of 5 codewords into 6 bytes. In mode 901 the
+// trailing bytes that don't fill a group are sent one per codeword.
+func byteCompaction(mode int, codewords []int, i int, out []byte) (int, []byte) {
+	length := codewords[0]
+	flush := func(value int64) {
+		for j := 5; j >= 0; j-- {
+			out = append(out, byte(value>>(8*uint(j))))
+		}
+	}
+
+	if mode == byteLatch {
+		var group []int
+		var value int64
+		if i >= length {
+			return i, out
+		}
+		next := codewords[i]
+		i++
+		end := false
+		for i < length && !end {
+			group = append(group, next)
+			value = 900*value + int64(next)
+			next = codewords[i]
+			i++
+			if next >= textLatch {
+				i--
+				end = true
+			} else if len(group) == 5 {
+				flush(value)
+				group, value = nil, 0
+			}
+		}
+		if i == length && next < textLatch {
+			group = append(group, next)
+		}
+		for _, b := range group {
+			out = append(out, byte(b))
+		}
+		return i, out
+	}
+
+	count := 0
+	var value int64
+	for i < length {
+		code := codewords[i]
+		i++
+		if code >= textLatch {
+			i--
+			break
+		}
+		count++
+		value = 900*value + int64(code)
+		if count == 5 {
+			flush(value)
+			count, value = 0, 0
+		}
+	}
+	return i, out
+}
+
+func numericCompaction(codewords []int, i int, out []byte) (int, []byte, error) {
+	length := codewords[0]
+	var group []int
+	end := false
+	for i < length && !end {
+		code := codewords[i]
+		i++
+		if i == length {
+			end = true
+		}
+		if code < textLatch {
+			group = append(group, code)
+		} else if code == textLatch || (isLatch(code) && code != numericLatch) {
+			i--
+			end = true
+		}
+		if len(group) > 0 && (len(group) == 15 || code == numericLatch || end) {
+			digits, err := base900ToBase10(group)
+			if err != nil {
+				return i, out, err
+			}
+			out = append(out, digits...)
+			group = nil
+		}
+	}
+	return i, out, nil
+}
+
+// Each numeric group encodes "1" followed by the digits, in base 900
+func base900ToBase10(group []int) (string, error) {
+	n := new(big.Int)
+	b900 := big.NewInt(900)
+	for _, c := range group {
+		n.Mul(n, b900)
+		n.Add(n, big.NewInt(int64(c)))
+	}
+	s := n.String()
+	if !strings.HasPrefix(s, "1") {
+		return "", errFormat
+	}
+	return s[1:], nil
+}
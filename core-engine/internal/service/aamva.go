@@ -0,0 +1,184 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AAMVALicense holds the fields of an AAMVA DL/ID card design standard barcode
+type AAMVALicense struct {
+	IssuerID       string `json:"issuer_id"` // IIN of the issuing jurisdiction
+	Version        int    `json:"version"`
+	DocumentType   string `json:"document_type"` // DL or ID
+	DocumentNumber string `json:"document_number"`
+	Surname        string `json:"surname"`
+	GivenNames     string `json:"given_names"`
+	DateOfBirth    string `json:"date_of_birth"` // YYYY-MM-DD
+	ExpiryDate     string `json:"expiry_date"`
+	IssueDate      string `json:"issue_date,omitempty"`
+	Sex            string `json:"sex"` // M, F or X
+	Street         string `json:"street,omitempty"`
+	City           string `json:"city,omitempty"`
+	State          string `json:"state,omitempty"`
+	PostalCode     string `json:"postal_code,omitempty"`
+	Country        string `json:"country,omitempty"`
+	Expired        bool   `json:"expired"`
+}
+
+var (
+	aamvaHeader   = regexp.MustCompile(`(?:ANSI |AAMVA)(\d{6})(\d{2})`)
+	aamvaSubfile  = regexp.MustCompile(`(DL|ID)(D[A-Z]{2})`)
+	aamvaElement  = regexp.MustCompile(`^D[A-Z]{2}`)
+	errNotAAMVA   = errors.New("barcode is not an AAMVA document")
+	errAAMVADates = errors.New("barcode dates are invalid")
+)
+
+// ParseAAMVA parses the text of a driver's license / ID card PDF417 barcode.
+// Subfile offsets are often wrong in the field, so elements are located by
+// their IDs rather than by the header designators.
+func ParseAAMVA(data []byte, now time.Time) (*AAMVALicense, error) {
+	text := string(data)
+	if !strings.HasPrefix(text, "@") {
+		return nil, errNotAAMVA
+	}
+	license := &AAMVALicense{}
+	if m := aamvaHeader.FindStringSubmatch(text); m != nil {
+		license.IssuerID = m[1]
+		license.Version, _ = strconv.Atoi(m[2])
+	} else {
+		return nil, errNotAAMVA
+	}
+
+	loc := aamvaSubfile.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil, errNotAAMVA
+	}
+	license.DocumentType = text[loc[2]:loc[3]]
+
+	elements := map[string]string{}
+	for _, line := range strings.FieldsFunc(text[loc[4]:], func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if aamvaElement.MatchString(line) {
+			elements[line[:3]] = strings.TrimSpace(line[3:])
+		}
+	}
+
+	license.DocumentNumber = elements["DAQ"]
+	license.Country = elements["DCG"]
+	license.State = elements["DAJ"]
+	license.City = elements["DAI"]
+	license.Street = elements["DAG"]
+	license.PostalCode = strings.TrimRight(elements["DAK"], "0 ") // Zip+4 is zero padded
+	license.Sex = aamvaSex(elements["DBC"])
+	license.Surname, license.GivenNames = aamvaNames(elements)
+
+	dob, err1 := aamvaDate(elements["DBB"], license.Country)
+	expiry, err2 := aamvaDate(elements["DBA"], license.Country)
+	if err1 != nil || err2 != nil {
+		return nil, errAAMVADates
+	}
+	license.DateOfBirth = dob.Format("2006-01-02")
+	license.ExpiryDate = expiry.Format("2006-01-02")
+	if issued, err := aamvaDate(elements["DBD"], license.Country); err == nil {
+		license.IssueDate = issued.Format("2006-01-02")
+	}
+	license.Expired = !now.Before(expiry.AddDate(0, 0, 1))
+	return license, nil
+}
+
+// Version 1 uses DAB/DAC or a combined DAA "LAST,FIRST,MIDDLE"; later versions DCS/DAC/DAD or DCT
+func aamvaNames(e map[string]string) (string, string) {
+	surname := firstNonEmpty(e["DCS"], e["DAB"])
+	given := strings.TrimSpace(e["DAC"] + " " + e["DAD"])
+	if given == "" {
+		given = strings.ReplaceAll(e["DCT"], ",", " ")
+	}
+	if surname == "" && e["DAA"] != "" {
+		parts := strings.SplitN(e["DAA"], ",", 2)
+		surname = parts[0]
+		if len(parts) > 1 && given == "" {
+			given = strings.ReplaceAll(parts[1], ",", " ")
+		}
+	}
+	return strings.TrimSpace(surname), strings.Join(strings.Fields(given), " ")
+}
+
+func aamvaSex(v string) string {
+	switch strings.ToUpper(v) {
+	case "1", "M":
+		return "M"
+	case "2", "F":
+		return "F"
+	}
+	return "X"
+}
+
+// US documents use MMDDCCYY, Canadian ones CCYYMMDD
+func aamvaDate(v, country string) (time.Time, error) {
+	if country == "CAN" || (len(v) == 8 && (strings.HasPrefix(v, "19") || strings.HasPrefix(v, "20")) && v[4:6] <= "12") {
+		if t, err := time.Parse("20060102", v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse("01022006", v)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// FieldMismatch is a field that differs between two sources of the same document
+type FieldMismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"` // Front of the document
+	Actual   string `json:"actual"`   // Barcode
+}
+
+// CompareDocumentFields compares the barcode data with the fields read from the
+// front. Only fields present on both sides are compared; names are compared
+// ignoring case, spacing and punctuation, given names by their first name.
+func CompareDocumentFields(barcode, front map[string]string) []FieldMismatch {
+	var mismatches []FieldMismatch
+	for _, field := range []string{"document_number", "surname", "given_names", "date_of_birth", "expiry_date"} {
+		a, b := barcode[field], front[field]
+		if a == "" || b == "" {
+			continue
+		}
+		na, nb := normalizeField(a), normalizeField(b)
+		if field == "given_names" {
+			na, nb = normalizeField(strings.Fields(a)[0]), normalizeField(strings.Fields(b)[0])
+		}
+		if na != nb {
+			mismatches = append(mismatches, FieldMismatch{Field: field, Expected: b, Actual: a})
+		}
+	}
+	return mismatches
+}
+
+func normalizeField(v string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(v) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Fields returns the comparable fields of the license
+func (l *AAMVALicense) Fields() map[string]string {
+	return map[string]string{
+		"document_number": l.DocumentNumber,
+		"surname":         l.Surname,
+		"given_names":     l.GivenNames,
+		"date_of_birth":   l.DateOfBirth,
+		"expiry_date":     l.ExpiryDate,
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/pdf417"
+)
+
+// BarcodeResult is stored in results["barcode"] by a barcode_decode step
+type BarcodeResult struct {
+	StepID     string          `json:"step_id"`
+	Field      string          `json:"field"` // Upload field the image came from
+	Format     string          `json:"format"`
+	License    *AAMVALicense   `json:"license,omitempty"`
+	Mismatches []FieldMismatch `json:"mismatches,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DecodedAt  time.Time       `json:"decoded_at"`
+}
+
+// DecodeLicenseBarcode reads the PDF417 barcode of a document image and parses its AAMVA data
+func DecodeLicenseBarcode(data []byte, now time.Time) (*AAMVALicense, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	text, err := pdf417.Decode(img)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAAMVA(text, now)
+}
+
+// FrontDocumentFields collects the fields read from the front of the document:
+// the MRZ when there is one, otherwise the OCR of the document_front image.
+func FrontDocumentFields(results map[string]interface{}) map[string]string {
+	var doc MRZDocument
+	if convertCollected(results["document"], &doc) && doc.DocumentNumber != "" {
+		return map[string]string{
+			"document_number": doc.DocumentNumber,
+			"surname":         doc.Surname,
+			"given_names":     doc.GivenNames,
+			"date_of_birth":   doc.DateOfBirth,
+			"expiry_date":     doc.ExpiryDate,
+		}
+	}
+
+	var runs map[string][]OCRRun
+	convertCollected(results["ocr"], &runs)
+	fields := map[string]string{}
+	for _, stepRuns := range runs {
+		for _, run := range stepRuns {
+			if run.Field != "document_front" || run.Error != "" {
+				continue
+			}
+			for name, f := range run.Fields {
+				fields[name] = f.Value
+			}
+		}
+	}
+	return fields
+}
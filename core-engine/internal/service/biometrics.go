@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BiometricDecision is the normalized outcome of a biometric check
+type BiometricDecision string
+
+const (
+	BiometricApprove BiometricDecision = "approve"
+	BiometricReview  BiometricDecision = "review"
+	BiometricReject  BiometricDecision = "reject"
+)
+
+// BiometricScore is the normalized output of a provider: a 0-1 score
+// (higher is better) plus the provider response for auditing.
+type BiometricScore struct {
+	Score float64         `json:"score"`
+	Raw   json.RawMessage `json:"raw,omitempty"`
+}
+
+// FaceMatcher compares the face on the document with the selfie
+type FaceMatcher interface {
+	Name() string
+	Match(ctx context.Context, document, selfie []byte) (*BiometricScore, error)
+}
+
+// LivenessChecker tells whether the selfie was taken from a live person
+type LivenessChecker interface {
+	Name() string
+	Check(ctx context.Context, selfie []byte) (*BiometricScore, error)
+}
+
+// BiometricCheck is what gets recorded for each check of a face_match step
+type BiometricCheck struct {
+	Provider  string            `json:"provider"`
+	Score     float64           `json:"score"`
+	Decision  BiometricDecision `json:"decision"`
+	LatencyMS int64             `json:"latency_ms"`
+	Raw       json.RawMessage   `json:"raw,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// BiometricResult is stored in results["biometrics"]
+type BiometricResult struct {
+	StepID    string            `json:"step_id"`
+	FaceMatch *BiometricCheck   `json:"face_match"`
+	Liveness  *BiometricCheck   `json:"liveness,omitempty"`
+	Decision  BiometricDecision `json:"decision"`
+	RanAt     time.Time         `json:"ran_at"`
+}
+
+// BiometricThresholds come from the face_match step config. Scores at or above
+// AutoApprove approve, scores below AutoReject reject, anything else goes to
+// manual review. AutoApprove 0 disables automatic approval.
+type BiometricThresholds struct {
+	AutoApprove float64 `json:"auto_approve_score"`
+	AutoReject  float64 `json:"auto_reject_score"`
+	Liveness    bool    `json:"liveness"`
+}
+
+// BiometricThresholdsFor reads the step config over the defaults
+func BiometricThresholdsFor(config map[string]interface{}) BiometricThresholds {
+	t := BiometricThresholds{AutoReject: 0.4, Liveness: true}
+	if v, ok := config["auto_approve_score"].(float64); ok {
+		t.AutoApprove = v
+	}
+	if v, ok := config["auto_reject_score"].(float64); ok {
+		t.AutoReject = v
+	}
+	if v, ok := config["liveness"].(bool); ok {
+		t.Liveness = v
+	}
+	return t
+}
+
+func (t BiometricThresholds) Decide(score float64) BiometricDecision {
+	switch {
+	case score < t.AutoReject:
+		return BiometricReject
+	case t.AutoApprove > 0 && score >= t.AutoApprove:
+		return BiometricApprove
+	}
+	return BiometricReview
+}
+
+// Combine returns the overall decision: any reject rejects, approval needs every check to approve
+func (r *BiometricResult) Combine() BiometricDecision {
+	checks := []*BiometricCheck{r.FaceMatch}
+	if r.Liveness != nil {
+		checks = append(checks, r.Liveness)
+	}
+	decision := BiometricApprove
+	for _, c := range checks {
+		switch c.Decision {
+		case BiometricReject:
+			return BiometricReject
+		case BiometricReview:
+			decision = BiometricReview
+		}
+	}
+	return decision
+}
+
+// BiometricsRegistry holds the configured providers. Steps pick one with
+// config.provider, otherwise the default one is used.
+type BiometricsRegistry struct {
+	matchers    map[string]FaceMatcher
+	checkers    map[string]LivenessChecker
+	defaultName string
+}
+
+func NewBiometricsRegistry(defaultName string) *BiometricsRegistry {
+	return &BiometricsRegistry{
+		matchers:    map[string]FaceMatcher{},
+		checkers:    map[string]LivenessChecker{},
+		defaultName: defaultName,
+	}
+}
+
+func (r *BiometricsRegistry) RegisterMatcher(m FaceMatcher) {
+	r.matchers[m.Name()] = m
+}
+
+func (r *BiometricsRegistry) RegisterLivenessChecker(c LivenessChecker) {
+	r.checkers[c.Name()] = c
+}
+
+// ForStep returns the face matcher and liveness checker selected by the step config
+func (r *BiometricsRegistry) ForStep(config map[string]interface{}) (FaceMatcher, LivenessChecker, error) {
+	name := r.defaultName
+	if v, ok := config["provider"].(string); ok && v != "" {
+		name = v
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("no face match provider configured")
+	}
+	m, ok := r.matchers[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown face match provider %q", name)
+	}
+	return m, r.checkers[name], nil
+}
+
+// UnavailableBiometrics is the result of a face match step whose provider
+// can't be used: the session goes to manual review
+func UnavailableBiometrics(stepID string, err error) *BiometricResult {
+	return &BiometricResult{
+		StepID:    stepID,
+		FaceMatch: &BiometricCheck{Error: err.Error(), Decision: BiometricReview},
+		Decision:  BiometricReview,
+		RanAt:     time.Now(),
+	}
+}
+
+// UnavailableLiveness is the liveness check of a step that requires it when
+// the face match provider has no liveness checker: the session goes to manual
+// review instead of being approved on the face match alone
+func UnavailableLiveness(provider string) *BiometricCheck {
+	return &BiometricCheck{
+		Provider: provider,
+		Error:    fmt.Sprintf("no liveness provider for %s", provider),
+		Decision: BiometricReview,
+	}
+}
+
+// RunFaceMatch calls the matcher and applies the thresholds
+func RunFaceMatch(ctx context.Context, m FaceMatcher, t BiometricThresholds, document, selfie []byte) *BiometricCheck {
+	start := time.Now()
+	score, err := m.Match(ctx, document, selfie)
+	return biometricCheck(m.Name(), start, score, err, t)
+}
+
+// RunLiveness calls the liveness checker and applies the thresholds
+func RunLiveness(ctx context.Context, c LivenessChecker, t BiometricThresholds, selfie []byte) *BiometricCheck {
+	start := time.Now()
+	score, err := c.Check(ctx, selfie)
+	return biometricCheck(c.Name(), start, score, err, t)
+}
+
+// A failing provider sends the session to manual review rather than rejecting it
+func biometricCheck(provider string, start time.Time, score *BiometricScore, err error, t BiometricThresholds) *BiometricCheck {
+	check := &BiometricCheck{Provider: provider, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		check.Error = err.Error()
+		check.Decision = BiometricReview
+		return check
+	}
+	check.Score = score.Score
+	check.Raw = score.Raw
+	check.Decision = t.Decide(score.Score)
+	return check
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MockBiometricsProviderName is the name steps use to select the mock provider
+const MockBiometricsProviderName = "mock"
+
+// MockBiometricsProvider returns the configured scores for every input, so
+// flows can be exercised end to end (approve/review/reject) without a vendor.
+type MockBiometricsProvider struct {
+	MatchScore    float64
+	LivenessScore float64
+}
+
+func NewMockBiometricsProvider(matchScore, livenessScore float64) *MockBiometricsProvider {
+	return &MockBiometricsProvider{MatchScore: matchScore, LivenessScore: livenessScore}
+}
+
+func (p *MockBiometricsProvider) Name() string {
+	return MockBiometricsProviderName
+}
+
+func (p *MockBiometricsProvider) Match(ctx context.Context, document, selfie []byte) (*BiometricScore, error) {
+	return mockScore("face_match", p.MatchScore)
+}
+
+func (p *MockBiometricsProvider) Check(ctx context.Context, selfie []byte) (*BiometricScore, error) {
+	return mockScore("liveness", p.LivenessScore)
+}
+
+func mockScore(check string, score float64) (*BiometricScore, error) {
+	raw, err := json.Marshal(map[string]interface{}{"provider": MockBiometricsProviderName, "check": check, "score": score})
+	if err != nil {
+		return nil, err
+	}
+	return &BiometricScore{Score: score, Raw: raw}, nil
+}
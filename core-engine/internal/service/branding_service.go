@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// Limits for tenant branding
+const (
+	MaxLogoSize           = 1 << 20 // 1 MB
+	MaxCopyOverrides      = 50
+	MaxCopyOverrideLength = 500
+)
+
+var (
+	colorPattern      = regexp.MustCompile(`^#([0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+	fontFamilyPattern = regexp.MustCompile(`^[A-Za-z0-9 \-]{1,64}$`)
+	copyKeyPattern    = regexp.MustCompile(`^[a-z0-9_.]{1,64}$`)
+)
+
+// AllowedLogoTypes maps sniffed content types to the stored file extension
+var AllowedLogoTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/webp": "webp",
+}
+
+// BrandingFromConfig reads the typed branding out of the tenant branding_config JSONB
+func BrandingFromConfig(config domain.JSONB) domain.Branding {
+	var b domain.Branding
+	raw, err := json.Marshal(config)
+	if err == nil {
+		json.Unmarshal(raw, &b)
+	}
+	return b
+}
+
+// BrandingToConfig stores the typed branding back as JSONB
+func BrandingToConfig(b domain.Branding) (domain.JSONB, error) {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	config := domain.JSONB{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SanitizeBranding validates branding sent by the tenant. Values end up in the
+// Secure Flow page, so only strict formats are accepted.
+func SanitizeBranding(b domain.Branding) (domain.Branding, error) {
+	colors := map[string]*string{
+		"primary_color":    &b.PrimaryColor,
+		"secondary_color":  &b.SecondaryColor,
+		"background_color": &b.BackgroundColor,
+		"text_color":       &b.TextColor,
+	}
+	for name, c := range colors {
+		*c = strings.TrimSpace(*c)
+		if *c != "" && !colorPattern.MatchString(*c) {
+			return b, fmt.Errorf("%s must be a hex color like #4F46E5", name)
+		}
+	}
+
+	b.FontFamily = strings.TrimSpace(b.FontFamily)
+	if b.FontFamily != "" && !fontFamilyPattern.MatchString(b.FontFamily) {
+		return b, errors.New("font_family contains invalid characters")
+	}
+
+	if b.PrivacyPolicyURL != "" {
+		u, err := url.Parse(b.PrivacyPolicyURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return b, errors.New("privacy_policy_url must be an absolute https URL")
+		}
+	}
+
+	if len(b.CopyOverrides) > MaxCopyOverrides {
+		return b, fmt.Errorf("copy_overrides supports at most %d entries", MaxCopyOverrides)
+	}
+	for k, v := range b.CopyOverrides {
+		if !copyKeyPattern.MatchString(k) {
+			return b, fmt.Errorf("copy override key %q is invalid", k)
+		}
+		if len(v) > MaxCopyOverrideLength || strings.ContainsAny(v, "<>") {
+			return b, fmt.Errorf("copy override %q must be plain text up to %d characters", k, MaxCopyOverrideLength)
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Prefix of the DNS name and of the TXT value that prove a custom domain
+const customDomainChallenge = "_idv-verification"
+
+var ErrDomainNotVerified = errors.New("verification TXT record not found")
+
+// CustomDomainChallenge returns the TXT record (name and value) the tenant
+// creates to prove it controls a custom domain
+func CustomDomainChallenge(customDomain, token string) (string, string) {
+	return customDomainChallenge + "." + customDomain, "idv-verification=" + token
+}
+
+// VerifyCustomDomain looks for the challenge TXT record of the domain
+func VerifyCustomDomain(ctx context.Context, customDomain, token string) error {
+	name, value := CustomDomainChallenge(customDomain, token)
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return ErrDomainNotVerified
+		}
+		return fmt.Errorf("failed to look up %s: %w", name, err)
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == value {
+			return nil
+		}
+	}
+	return ErrDomainNotVerified
+}
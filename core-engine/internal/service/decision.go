@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// Decision precedence when several rules match
+var decisionRank = map[domain.SessionStatus]int{
+	domain.StatusApproved: 1,
+	domain.StatusReview:   2,
+	domain.StatusRejected: 3,
+}
+
+// DefaultDecisionPolicy is used by flows without rules of their own: the
+// biometric decision settles the session, everything else goes to review.
+func DefaultDecisionPolicy() domain.DecisionPolicy {
+	return domain.DecisionPolicy{
+		Rules: []domain.DecisionRule{
+			{ID: "biometrics_reject", When: `biometrics.decision == "reject"`, Decision: domain.StatusRejected, Reason: "biometric_mismatch"},
+			{ID: "biometrics_approve", When: `biometrics.decision == "approve"`, Decision: domain.StatusApproved, Reason: "biometric_match"},
+		},
+		Default: domain.StatusReview,
+	}
+}
+
+// PolicyFor returns the decision policy of a flow
+func PolicyFor(flow *domain.Flow) domain.DecisionPolicy {
+	if len(flow.DecisionPolicy.Rules) == 0 && flow.DecisionPolicy.Default == "" {
+		return DefaultDecisionPolicy()
+	}
+	return flow.DecisionPolicy
+}
+
+// DecisionOutcome is what the policy decided, stored in results["decision"]
+type DecisionOutcome struct {
+	Status       domain.SessionStatus `json:"status"`
+	Reasons      []string             `json:"reasons"`
+	MatchedRules []string             `json:"matched_rules"`
+	DecidedAt    time.Time            `json:"decided_at"`
+}
+
+// DecisionFacts is what rule conditions see: the engine results as JSON, the
+// session metadata, what the user submitted under data (data.country) and a
+// summary of the risk signals (risk.high, risk.medium, risk.low, risk.count
+// and risk.codes). User data has its own namespace so it can never stand in
+// for a result.
+func DecisionFacts(session *domain.Session) map[string]interface{} {
+	facts := map[string]interface{}{}
+	convertCollected(map[string]interface{}(session.Results), &facts)
+
+	data := map[string]interface{}{}
+	convertCollected(map[string]interface{}(session.CollectedData), &data)
+	facts["data"] = data
+
+	metadata := map[string]interface{}{}
+	convertCollected(map[string]interface{}(session.Metadata), &metadata)
+	facts["metadata"] = metadata
+
+	risk := map[string]interface{}{RiskLow: 0.0, RiskMedium: 0.0, RiskHigh: 0.0}
+	codes := []interface{}{}
+	signals := RiskSignals(session.Results)
+	for _, s := range signals {
+		if n, ok := risk[s.Severity].(float64); ok {
+			risk[s.Severity] = n + 1
+		}
+		codes = append(codes, s.Code)
+	}
+	risk["count"] = float64(len(signals))
+	risk["codes"] = codes
+	facts["risk"] = risk
+	return facts
+}
+
+// EvaluatePolicy decides a finished session. Every matching rule votes; the most
+// severe decision wins and the reasons of the rules behind it are recorded.
+func EvaluatePolicy(policy domain.DecisionPolicy, session *domain.Session) (*DecisionOutcome, error) {
+	facts := DecisionFacts(session)
+	outcome := &DecisionOutcome{Reasons: []string{}, MatchedRules: []string{}, DecidedAt: time.Now()}
+
+	type vote struct {
+		status domain.SessionStatus
+		reason string
+	}
+	var votes []vote
+	for i, rule := range policy.Rules {
+		cond, err := CompileCondition(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", ruleName(rule, i), err)
+		}
+		if !cond.Eval(facts) {
+			continue
+		}
+		outcome.MatchedRules = append(outcome.MatchedRules, ruleName(rule, i))
+		votes = append(votes, vote{rule.Decision, firstNonEmpty(rule.Reason, ruleName(rule, i))})
+		if decisionRank[rule.Decision] > decisionRank[outcome.Status] {
+			outcome.Status = rule.Decision
+		}
+	}
+
+	if outcome.Status == "" {
+		outcome.Status = policy.Default
+		if outcome.Status == "" {
+			outcome.Status = domain.StatusReview
+		}
+		outcome.Reasons = append(outcome.Reasons, "no_rule_matched")
+		return outcome, nil
+	}
+	for _, v := range votes {
+		if v.status == outcome.Status {
+			outcome.Reasons = append(outcome.Reasons, v.reason)
+		}
+	}
+	return outcome, nil
+}
+
+func ruleName(rule domain.DecisionRule, i int) string {
+	if rule.ID != "" {
+		return rule.ID
+	}
+	return fmt.Sprintf("rule_%d", i+1)
+}
+
+// ValidateDecisionPolicy reports rules that can't be evaluated
+func ValidateDecisionPolicy(policy domain.DecisionPolicy) []FlowIssue {
+	issues := []FlowIssue{}
+	if policy.Default != "" && decisionRank[policy.Default] == 0 {
+		issues = append(issues, FlowIssue{Severity: SeverityError, Message: fmt.Sprintf("decision policy default %q must be APPROVED, REJECTED or REVIEW_REQUIRED", policy.Default)})
+	}
+	seen := make(map[string]bool)
+	for i, rule := range policy.Rules {
+		name := ruleName(rule, i)
+		if seen[name] {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: fmt.Sprintf("decision rule %s: duplicated id", name)})
+		}
+		seen[name] = true
+		if decisionRank[rule.Decision] == 0 {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: fmt.Sprintf("decision rule %s: decision must be APPROVED, REJECTED or REVIEW_REQUIRED", name)})
+		}
+		if _, err := CompileCondition(rule.When); err != nil {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: fmt.Sprintf("decision rule %s: %v", name, err)})
+		}
+		if rule.Reason == "" {
+			issues = append(issues, FlowIssue{Severity: SeverityWarning, Message: fmt.Sprintf("decision rule %s has no reason", name)})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,480 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Conditions of a decision policy are small boolean expressions over the facts
+// of a session (its engine results, the user data under data. and a few
+// derived values):
+//
+//	biometrics.face_match.score >= 0.9 and risk.high == 0
+//	not document.expired or contains(risk.codes, "barcode_front_mismatch")
+//	data.country == "MX"
+//
+// Paths are dot separated keys into the facts (numbers index lists); a path that
+// doesn't exist is null. Supported are == != < <= > >=, and/or/not (also && || !),
+// parentheses, numbers, "strings", true, false, null and the functions
+// has(path), count(path) and contains(path, value). Comparing values of
+// different types is false, a bare value is true unless it is false, null, 0, ""
+// or empty.
+type Condition struct {
+	source string
+	root   condNode
+}
+
+// CompileCondition parses a rule condition
+func CompileCondition(source string) (*Condition, error) {
+	tokens, err := lexCondition(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &condParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+	return &Condition{source: source, root: root}, nil
+}
+
+func (c *Condition) String() string { return c.source }
+
+// Eval reports whether the condition holds for the facts
+func (c *Condition) Eval(facts map[string]interface{}) bool {
+	return truthy(c.root.eval(facts))
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type condToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexCondition(src string) ([]condToken, error) {
+	var tokens []condToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, condToken{tokLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, condToken{tokRParen, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, condToken{tokComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, condToken{tokString, b.String(), start})
+		case strings.ContainsRune("=!<>&|", r):
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||":
+				tokens = append(tokens, condToken{tokOp, two, start})
+				i += 2
+				continue
+			}
+			if r == '<' || r == '>' || r == '!' {
+				tokens = append(tokens, condToken{tokOp, string(r), start})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected %q at position %d", string(r), start+1)
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, condToken{tokNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_-.", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, condToken{tokIdent, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", string(r), i+1)
+		}
+	}
+	return append(tokens, condToken{kind: tokEOF, pos: len(runes)}), nil
+}
+
+type condParser struct {
+	tokens []condToken
+	pos    int
+}
+
+func (p *condParser) peek() condToken { return p.tokens[p.pos] }
+
+func (p *condParser) next() condToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *condParser) isWord(words ...string) bool {
+	t := p.peek()
+	for _, w := range words {
+		if (t.kind == tokIdent || t.kind == tokOp) && strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *condParser) parseOr() (condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (condNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("and", "&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseNot() (condNode, error) {
+	if p.isWord("not", "!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *condParser) parseComparison() (condNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokOp {
+		switch t.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{op: t.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseOperand() (condNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("missing ) for ( at position %d", t.pos+1)
+		}
+		return inner, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos+1)
+		}
+		return literalNode{n}, nil
+	case tokString:
+		return literalNode{t.text}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		case "null":
+			return literalNode{nil}, nil
+		case "and", "or", "not":
+			return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t)
+		}
+		return pathNode(strings.Split(t.text, ".")), nil
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+}
+
+var conditionFuncs = map[string]int{"has": 1, "count": 1, "contains": 2}
+
+func (p *condParser) parseCall(name condToken) (condNode, error) {
+	fn := strings.ToLower(name.text)
+	arity, ok := conditionFuncs[fn]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos+1)
+	}
+	p.next() // (
+	var args []condNode
+	for p.peek().kind != tokRParen {
+		if len(args) > 0 {
+			if p.next().kind != tokComma {
+				return nil, fmt.Errorf("expected , in %s() at position %d", fn, name.pos+1)
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next() // )
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s() takes %d argument(s), got %d", fn, arity, len(args))
+	}
+	if _, ok := args[0].(pathNode); !ok && fn == "has" {
+		return nil, fmt.Errorf("has() takes a path")
+	}
+	return callNode{fn: fn, args: args}, nil
+}
+
+type condNode interface {
+	eval(facts map[string]interface{}) interface{}
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(map[string]interface{}) interface{} { return n.value }
+
+type pathNode []string
+
+func (n pathNode) eval(facts map[string]interface{}) interface{} {
+	value, _ := lookupFact(facts, n)
+	return value
+}
+
+func lookupFact(facts map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = facts
+	for _, key := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+type notNode struct{ operand condNode }
+
+func (n notNode) eval(facts map[string]interface{}) interface{} {
+	return !truthy(n.operand.eval(facts))
+}
+
+type andNode struct{ left, right condNode }
+
+func (n andNode) eval(facts map[string]interface{}) interface{} {
+	return truthy(n.left.eval(facts)) && truthy(n.right.eval(facts))
+}
+
+type orNode struct{ left, right condNode }
+
+func (n orNode) eval(facts map[string]interface{}) interface{} {
+	return truthy(n.left.eval(facts)) || truthy(n.right.eval(facts))
+}
+
+type compareNode struct {
+	op          string
+	left, right condNode
+}
+
+func (n compareNode) eval(facts map[string]interface{}) interface{} {
+	a, b := n.left.eval(facts), n.right.eval(facts)
+	switch n.op {
+	case "==":
+		return equalValues(a, b)
+	case "!=":
+		return !equalValues(a, b)
+	}
+
+	var cmp int
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		cmp = compareFloats(x, y)
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(x, y) // ISO dates compare as strings
+	default:
+		return false
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func equalValues(a, b interface{}) bool {
+	switch a.(type) {
+	case nil, float64, string, bool:
+		return a == b
+	}
+	return false
+}
+
+type callNode struct {
+	fn   string
+	args []condNode
+}
+
+func (n callNode) eval(facts map[string]interface{}) interface{} {
+	switch n.fn {
+	case "has":
+		_, ok := lookupFact(facts, n.args[0].(pathNode))
+		return ok
+	case "count":
+		switch v := n.args[0].eval(facts).(type) {
+		case []interface{}:
+			return float64(len(v))
+		case map[string]interface{}:
+			return float64(len(v))
+		case nil:
+			return float64(0)
+		}
+		return float64(1)
+	case "contains":
+		needle := n.args[1].eval(facts)
+		switch v := n.args[0].eval(facts).(type) {
+		case []interface{}:
+			for _, item := range v {
+				if equalValues(item, needle) {
+					return true
+				}
+			}
+		case map[string]interface{}:
+			if key, ok := needle.(string); ok {
+				_, found := v[key]
+				return found
+			}
+		case string:
+			if s, ok := needle.(string); ok {
+				return strings.Contains(v, s)
+			}
+		}
+		return false
+	}
+	return nil
+}
+
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case []interface{}:
+		return len(x) > 0
+	case map[string]interface{}:
+		return len(x) > 0
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+func TestConditionEval(t *testing.T) {
+	facts := map[string]interface{}{
+		"yes": true,
+		"no":  false,
+		"biometrics": map[string]interface{}{
+			"decision":   "approve",
+			"face_match": map[string]interface{}{"score": 0.93},
+		},
+		"document": map[string]interface{}{"expired": false, "expiry_date": "2030-01-31"},
+		"risk":     map[string]interface{}{"high": 1.0, "codes": []interface{}{"multiple_ips", "barcode_front_mismatch"}},
+		"ocr":      map[string]interface{}{"front": []interface{}{map[string]interface{}{"mrz": "P<UTO"}}},
+	}
+	tests := []struct {
+		when string
+		want bool
+	}{
+		// Precedence: not binds tighter than and, and tighter than or
+		{"yes or no and no", true},
+		{"(yes or no) and no", false},
+		{"not no and yes", true},
+		{"not (no or yes)", false},
+		{"no or not no and yes", true},
+		{"! no && yes || no", true},
+		{"NOT yes OR no", false},
+		{"not not yes", true},
+
+		{"biometrics.face_match.score >= 0.9 and risk.high == 0", false},
+		{"biometrics.face_match.score >= 0.9 and risk.high <= 1", true},
+		{`biometrics.decision == "approve"`, true},
+		{`biometrics.decision != 'approve'`, false},
+		{`document.expiry_date > "2029-12-31"`, true}, // ISO dates compare as strings
+		{"risk.high > -1", true},
+
+		// Missing paths are null; comparing different types is false
+		{"missing.path == null", true},
+		{"missing.path", false},
+		{"missing.path < 1", false},
+		{`biometrics.face_match.score == "0.93"`, false},
+		{`biometrics.decision > 1`, false},
+
+		{"has(document.expired)", true},
+		{"has(document.number)", false},
+		{"count(risk.codes) == 2", true},
+		{`contains(risk.codes, "barcode_front_mismatch")`, true},
+		{`contains(risk.codes, "duplicate_selfie")`, false},
+		{`ocr.front.0.mrz == "P<UTO"`, true},
+		{"ocr.front.1.mrz == null", true},
+		{"document.expired", false},
+		{"risk.codes", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			cond, err := CompileCondition(tt.when)
+			if err != nil {
+				t.Fatalf("CompileCondition: %v", err)
+			}
+			if got := cond.Eval(facts); got != tt.want {
+				t.Errorf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileConditionErrors(t *testing.T) {
+	tests := []struct {
+		when string
+		want string
+	}{
+		{"", "unexpected end of condition"},
+		{"a ==", "unexpected end of condition"},
+		{"(a or b", "missing ) for ( at position 1"},
+		{"a b", `unexpected "b" at position 3`},
+		{`a == "open`, "unterminated string at position 6"},
+		{"a = 1", `unexpected "=" at position 3`},
+		{"a and or b", `unexpected "or" at position 7`},
+		{"size(a) > 1", `unknown function "size" at position 1`},
+		{"contains(a)", "contains() takes 2 argument(s), got 1"},
+		{"has(1)", "has() takes a path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			_, err := CompileCondition(tt.when)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("CompileCondition error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	session := &domain.Session{
+		Results: domain.JSONB{
+			"biometrics":   map[string]interface{}{"decision": "approve"},
+			"risk_signals": []interface{}{map[string]interface{}{"code": "multiple_ips", "severity": RiskMedium}},
+		},
+		CollectedData: domain.JSONB{
+			"country":    "MX",
+			"biometrics": map[string]interface{}{"decision": "reject"}, // Can't stand in for the engine result
+		},
+		Metadata: domain.JSONB{"channel": "web"},
+	}
+	approve := domain.DecisionRule{ID: "approve", When: `biometrics.decision == "approve"`, Decision: domain.StatusApproved, Reason: "biometric_match"}
+	tests := []struct {
+		name    string
+		policy  domain.DecisionPolicy
+		status  domain.SessionStatus
+		reasons []string
+		matched []string
+	}{
+		{"approve", domain.DecisionPolicy{Rules: []domain.DecisionRule{approve}}, domain.StatusApproved, []string{"biometric_match"}, []string{"approve"}},
+		{
+			"review wins over approve",
+			domain.DecisionPolicy{Rules: []domain.DecisionRule{
+				approve,
+				{When: "risk.medium > 0", Decision: domain.StatusReview, Reason: "medium_risk"},
+			}},
+			domain.StatusReview, []string{"medium_risk"}, []string{"approve", "rule_2"},
+		},
+		{
+			"reject wins over review, reasons of every rejecting rule",
+			domain.DecisionPolicy{Rules: []domain.DecisionRule{
+				{ID: "foreign", When: `data.country != "US"`, Decision: domain.StatusRejected, Reason: "unsupported_country"},
+				{ID: "risky", When: `contains(risk.codes, "multiple_ips")`, Decision: domain.StatusReview},
+				{ID: "web", When: `metadata.channel == "web"`, Decision: domain.StatusRejected},
+				approve,
+			}},
+			domain.StatusRejected, []string{"unsupported_country", "web"}, []string{"foreign", "risky", "web", "approve"},
+		},
+		{
+			"default when nothing matches",
+			domain.DecisionPolicy{Rules: []domain.DecisionRule{{When: `data.country == "US"`, Decision: domain.StatusApproved}}, Default: domain.StatusRejected},
+			domain.StatusRejected, []string{"no_rule_matched"}, []string{},
+		},
+		{"review without a default", domain.DecisionPolicy{}, domain.StatusReview, []string{"no_rule_matched"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, err := EvaluatePolicy(tt.policy, session)
+			if err != nil {
+				t.Fatalf("EvaluatePolicy: %v", err)
+			}
+			if outcome.Status != tt.status || !reflect.DeepEqual(outcome.Reasons, tt.reasons) || !reflect.DeepEqual(outcome.MatchedRules, tt.matched) {
+				t.Errorf("EvaluatePolicy = %s %q %q, want %s %q %q", outcome.Status, outcome.Reasons, outcome.MatchedRules, tt.status, tt.reasons, tt.matched)
+			}
+		})
+	}
+
+	policy := domain.DecisionPolicy{Rules: []domain.DecisionRule{{ID: "broken", When: "a ==", Decision: domain.StatusApproved}}}
+	if _, err := EvaluatePolicy(policy, session); err == nil || err.Error() != "rule broken: unexpected end of condition" {
+		t.Errorf("EvaluatePolicy with a broken rule = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// More distinct IPs than this in one session is suspicious (mobile networks
+// and Wi-Fi switches account for a couple)
+const maxSessionIPs = 3
+
+// DeviceSummary describes who used a session, stored in results["device"] so
+// decision rules can use it (e.g. device.ip_count > 3)
+type DeviceSummary struct {
+	Requests    int      `json:"requests"`
+	IPCount     int      `json:"ip_count"`
+	DeviceCount int      `json:"device_count"` // Distinct client fingerprints
+	Handoffs    int      `json:"handoffs"`     // Moves to another device through a handoff link
+	AgentCount  int      `json:"user_agent_count"`
+	Countries   []string `json:"countries"` // Alpha-2, empty without geolocation
+	Languages   []string `json:"languages"` // Primary Accept-Language of each request
+	Headless    bool     `json:"headless"`  // A user agent looked like automation
+	FirstIP     string   `json:"first_ip"`
+	LastIP      string   `json:"last_ip"`
+}
+
+// Substrings of user agents of headless browsers and HTTP libraries
+var automationAgents = []string{
+	"headlesschrome", "phantomjs", "selenium", "webdriver", "puppeteer", "playwright",
+	"python-requests", "python-urllib", "curl/", "wget/", "go-http-client", "okhttp", "httpclient", "node-fetch", "axios/",
+}
+
+// IsAutomatedUserAgent reports whether a user agent is missing or belongs to a
+// headless browser or an HTTP library rather than a person's browser
+func IsAutomatedUserAgent(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return true
+	}
+	return slices.ContainsFunc(automationAgents, func(s string) bool { return strings.Contains(ua, s) })
+}
+
+// SummarizeAccesses aggregates the Secure Flow calls of a session
+func SummarizeAccesses(accesses []domain.SessionAccess) DeviceSummary {
+	summary := DeviceSummary{Requests: len(accesses), Countries: []string{}, Languages: []string{}}
+	ips, devices, agents := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, a := range accesses {
+		ips[a.IP] = true
+		if a.DeviceID != "" {
+			devices[a.DeviceID] = true
+		}
+		agents[a.UserAgent] = true
+		if a.Endpoint == "handoff" {
+			summary.Handoffs++
+		}
+		if IsAutomatedUserAgent(a.UserAgent) {
+			summary.Headless = true
+		}
+		if a.Country != "" && !slices.Contains(summary.Countries, a.Country) {
+			summary.Countries = append(summary.Countries, a.Country)
+		}
+		if lang := primaryLanguage(a.AcceptLanguage); lang != "" && !slices.Contains(summary.Languages, lang) {
+			summary.Languages = append(summary.Languages, lang)
+		}
+	}
+	summary.IPCount, summary.DeviceCount, summary.AgentCount = len(ips), len(devices), len(agents)
+	if n := len(accesses); n > 0 {
+		summary.FirstIP, summary.LastIP = accesses[0].IP, accesses[n-1].IP
+	}
+	return summary
+}
+
+// primaryLanguage is the first tag of an Accept-Language header ("es-MX")
+func primaryLanguage(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	return strings.TrimSpace(tag)
+}
+
+// DeviceSignals raises risk signals from the device summary: the token used from
+// many IPs or from more devices than handoffs explain, automation user agents
+// and requests coming from a country other than the declared nationality.
+func DeviceSignals(summary DeviceSummary, results, collected map[string]interface{}) []RiskSignal {
+	var signals []RiskSignal
+	if summary.IPCount > maxSessionIPs {
+		signals = append(signals, RiskSignal{Code: "multiple_ips", Severity: RiskMedium, Source: "device", Detail: fmt.Sprintf("session used from %d IPs", summary.IPCount)})
+	}
+	// Each handoff legitimately brings one more device
+	if summary.DeviceCount > 1+summary.Handoffs {
+		signals = append(signals, RiskSignal{Code: "multiple_devices", Severity: RiskMedium, Source: "device", Detail: fmt.Sprintf("session used from %d devices", summary.DeviceCount)})
+	}
+	if summary.Headless {
+		signals = append(signals, RiskSignal{Code: "automated_user_agent", Severity: RiskHigh, Source: "device", Detail: "headless browser or HTTP library"})
+	}
+
+	nationality := declaredNationality(results, collected)
+	if nationality != "" && len(summary.Countries) > 0 && !slices.Contains(summary.Countries, nationality) {
+		signals = append(signals, RiskSignal{
+			Code:     "country_mismatch",
+			Severity: RiskMedium,
+			Source:   "device",
+			Detail:   fmt.Sprintf("nationality %s, requests from %s", nationality, strings.Join(summary.Countries, ", ")),
+		})
+	}
+	return signals
+}
+
+// declaredNationality is the alpha-2 nationality from the document, or from a
+// "nationality" form field
+func declaredNationality(results, collected map[string]interface{}) string {
+	var doc MRZDocument
+	if convertCollected(results["document"], &doc) && doc.Nationality != "" {
+		return CountryAlpha2(doc.Nationality)
+	}
+	if nationality, ok := collected["nationality"].(string); ok {
+		return CountryAlpha2(nationality)
+	}
+	return ""
+}
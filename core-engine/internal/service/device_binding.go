@@ -0,0 +1,42 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/url"
+	"time"
+)
+
+// HandoffTTL is how long a "continue on another device" link can be used
+const HandoffTTL = 10 * time.Minute
+
+// NewDeviceSecret returns the secret that binds a session to a device and the
+// hash stored for it
+func NewDeviceSecret() (string, string, error) {
+	secret, err := NewSigningSecret()
+	if err != nil {
+		return "", "", err
+	}
+	return secret, HashDeviceSecret(secret), nil
+}
+
+// HashDeviceSecret hashes device secrets and handoff codes, only hashes are stored
+func HashDeviceSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeviceSecretMatches compares a secret sent by the client with the stored hash
+func DeviceSecretMatches(hash, secret string) bool {
+	if hash == "" || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashDeviceSecret(secret))) == 1
+}
+
+// BuildHandoffURL returns the Secure Flow link that moves a session to the
+// device that opens it
+func BuildHandoffURL(baseURL, customDomain, token, code string) string {
+	return BuildFlowStartURL(baseURL, customDomain, token) + "&handoff=" + url.QueryEscape(code)
+}
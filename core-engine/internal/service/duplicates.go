@@ -0,0 +1,139 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/aoricaan/idv-core/internal/validators"
+	"golang.org/x/image/draw"
+)
+
+// DuplicateImageDistance is the largest Hamming distance between perceptual
+// hashes of two images considered the same picture (re-encoded, resized...)
+const DuplicateImageDistance = 6
+
+// Upload fields whose images are fingerprinted
+var fingerprintedUploads = map[string]string{
+	"document_front": domain.FingerprintDocumentImage,
+	"document_back":  domain.FingerprintDocumentImage,
+	"selfie":         domain.FingerprintSelfieImage,
+}
+
+// PerceptualHash is the difference hash (dHash) of an image: it compares the
+// brightness of neighbouring pixels of a 9x8 grayscale copy, so it survives
+// re-encoding, resizing and small edits. Returned as 16 hex digits.
+func PerceptualHash(img image.Image) string {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.CatmullRom.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.At(x, y).(color.Gray).Y < small.At(x+1, y).(color.Gray).Y {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// SessionFingerprints computes the fingerprints of what the engine read from a
+// session so far (its results). Identity fields are hashed with the tenant ID, so they can only be
+// compared within the tenant and are never stored in clear.
+func SessionFingerprints(tenantID string, results map[string]interface{}) []domain.Fingerprint {
+	var fingerprints []domain.Fingerprint
+
+	var uploads map[string]ImageReport
+	convertCollected(results["uploads"], &uploads)
+	for field, kind := range fingerprintedUploads {
+		report, ok := uploads[field]
+		if !ok || report.PerceptualHash == "" {
+			continue
+		}
+		// A zero hash is a flat image (blank capture), it would match any other one
+		bits, err := strconv.ParseUint(report.PerceptualHash, 16, 64)
+		if err != nil || bits == 0 {
+			continue
+		}
+		phash := int64(bits)
+		fingerprints = append(fingerprints, domain.Fingerprint{Kind: kind, Value: report.PerceptualHash, PHash: &phash})
+	}
+
+	identity := func(kind, value string) {
+		normalized := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToUpper(r)
+			}
+			return -1
+		}, value)
+		if normalized == "" {
+			return
+		}
+		sum := sha256.Sum256([]byte(tenantID + ":" + kind + ":" + normalized))
+		fingerprints = append(fingerprints, domain.Fingerprint{Kind: kind, Value: hex.EncodeToString(sum[:])})
+	}
+
+	var doc MRZDocument
+	if convertCollected(results["document"], &doc) {
+		identity(domain.FingerprintDocumentNumber, doc.DocumentNumber)
+	}
+	var curp validators.CURPCheck
+	if convertCollected(results["curp_validation"], &curp) && curp.CURP != nil {
+		identity(domain.FingerprintCURP, curp.CURP.Value)
+	}
+	return fingerprints
+}
+
+// Risk signal raised for each kind of reused fingerprint
+var duplicateSignalCodes = map[string]string{
+	domain.FingerprintDocumentImage:  "duplicate_document_image",
+	domain.FingerprintSelfieImage:    "duplicate_selfie",
+	domain.FingerprintDocumentNumber: "duplicate_document_number",
+	domain.FingerprintCURP:           "duplicate_curp",
+}
+
+// DuplicateSignals turns matches with sessions of other user references into
+// risk signals, one per kind. Matches with the same (non-empty) user_reference
+// are retries of the same user; sessions without a reference can't be told
+// apart, each one counts as another user.
+func DuplicateSignals(userReference string, matches []domain.DuplicateMatch) []RiskSignal {
+	others := make(map[string]map[string]bool) // kind -> user references
+	var kinds []string
+	for _, m := range matches {
+		if userReference != "" && m.UserReference == userReference {
+			continue
+		}
+		if others[m.Kind] == nil {
+			others[m.Kind] = make(map[string]bool)
+			kinds = append(kinds, m.Kind)
+		}
+		other := m.UserReference
+		if other == "" {
+			other = "session:" + m.SessionID.String()
+		}
+		others[m.Kind][other] = true
+	}
+
+	var signals []RiskSignal
+	for _, kind := range kinds {
+		code := duplicateSignalCodes[kind]
+		if code == "" {
+			continue
+		}
+		signals = append(signals, RiskSignal{
+			Code:     code,
+			Severity: RiskHigh,
+			Source:   "duplicates",
+			Detail:   fmt.Sprintf("also used by %d other user reference(s)", len(others[kind])),
+		})
+	}
+	return signals
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// Evidence kinds
+const (
+	EvidenceFile = "file" // Uploaded object, URL is presigned by the caller
+	EvidenceData = "data" // Value submitted by the user
+)
+
+// EvidenceItem is one artifact of a step
+type EvidenceItem struct {
+	Key          string      `json:"key"` // collected_data key
+	Kind         string      `json:"kind"`
+	Value        interface{} `json:"value,omitempty"` // Data only
+	ContentType  string      `json:"content_type,omitempty"`
+	URL          string      `json:"url,omitempty"`
+	ThumbnailURL string      `json:"thumbnail_url,omitempty"`
+	Width        int         `json:"width,omitempty"`
+	Height       int         `json:"height,omitempty"`
+
+	ObjectKey    string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+// EvidenceGroup is what a step of the flow collected. Files no step declares
+// are grouped under an empty StepID, what the redone steps had collected before
+// a resubmission under the Round that archived it.
+type EvidenceGroup struct {
+	StepID string         `json:"step_id"`
+	Type   string         `json:"type"`
+	Label  string         `json:"label"`
+	Round  int            `json:"round,omitempty"`
+	Items  []EvidenceItem `json:"items"`
+}
+
+// Output keys of the built-in step types, used when the step declares none
+var builtinStepOutputs = map[string][]string{
+	"document_capture": {"document_front", "document_back", "mrz"},
+	"selfie":           {"selfie"},
+}
+
+// StepOutputKeys lists the collected_data keys a step writes: the "outputs"
+// declared in its config or base_config, the ids of its form fields, or the
+// keys of its built-in type.
+func StepOutputKeys(step domain.StepConfig) []string {
+	var keys []string
+	add := func(key string) {
+		if key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, config := range []map[string]interface{}{step.Config, step.BaseConfig} {
+		outputs, _ := config["outputs"].([]interface{})
+		for _, o := range outputs {
+			key, _ := o.(string)
+			add(key)
+		}
+		fields, _ := config["fields"].([]interface{})
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			id, _ := field["id"].(string)
+			add(id)
+		}
+	}
+	if len(keys) == 0 {
+		for _, key := range builtinStepOutputs[step.Type] {
+			add(key)
+		}
+	}
+	return keys
+}
+
+// CollectEvidence groups the artifacts of a session by the flow step that
+// produced them, in flow order, followed by one group per resubmission round
+// with what the redone steps had collected before. Only keys with a value are
+// included.
+func CollectEvidence(flow *domain.Flow, session *domain.Session) []EvidenceGroup {
+	prefix := SessionObjectPrefix(flow.TenantID.String(), session.ID.String())
+	var uploads map[string]ImageReport
+	convertCollected(session.Results["uploads"], &uploads)
+	item := func(key string) (EvidenceItem, bool) {
+		return evidenceItem(prefix, session.CollectedData, uploads, key)
+	}
+
+	groups := []EvidenceGroup{}
+	claimed := make(map[string]bool)
+	for _, step := range flow.StepsConfiguration {
+		group := EvidenceGroup{StepID: step.StepID, Type: step.Type, Label: StepLabel(flow, step), Items: []EvidenceItem{}}
+		for _, key := range StepOutputKeys(step) {
+			claimed[key] = true
+			if it, ok := item(key); ok {
+				group.Items = append(group.Items, it)
+			}
+		}
+		if len(group.Items) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	// Session files no step declares (e.g. custom steps without "outputs")
+	other := EvidenceGroup{Label: "Other uploads", Items: []EvidenceItem{}}
+	keys := make([]string, 0, len(session.CollectedData))
+	for key := range session.CollectedData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if claimed[key] {
+			continue
+		}
+		if it, ok := item(key); ok && it.Kind == EvidenceFile {
+			other.Items = append(other.Items, it)
+		}
+	}
+	if len(other.Items) > 0 {
+		groups = append(groups, other)
+	}
+
+	var history []PreviousSubmission
+	convertCollected(session.Results["previous_submissions"], &history)
+	for _, round := range history {
+		var archived map[string]ImageReport
+		convertCollected(round.Results["uploads"], &archived)
+		group := EvidenceGroup{Label: fmt.Sprintf("Before resubmission %d", round.Round), Round: round.Round, Items: []EvidenceItem{}}
+		for _, step := range flow.StepsConfiguration {
+			if !slices.Contains(round.StepIDs, step.StepID) {
+				continue
+			}
+			for _, key := range StepOutputKeys(step) {
+				if it, ok := evidenceItem(prefix, round.Data, archived, key); ok {
+					group.Items = append(group.Items, it)
+				}
+			}
+		}
+		if len(group.Items) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// evidenceItem reads a key of the submitted data: an object of the session
+// (with its upload report) or a plain value
+func evidenceItem(prefix string, data map[string]interface{}, uploads map[string]ImageReport, key string) (EvidenceItem, bool) {
+	value, ok := data[key]
+	if !ok || value == nil || value == "" {
+		return EvidenceItem{}, false
+	}
+	objectKey, isString := value.(string)
+	if !isString || !strings.HasPrefix(objectKey, prefix) {
+		return EvidenceItem{Key: key, Kind: EvidenceData, Value: value}, true
+	}
+	file := EvidenceItem{Key: key, Kind: EvidenceFile, ObjectKey: objectKey, ContentType: objectContentType(objectKey)}
+	if report, ok := uploads[key]; ok && report.Key == objectKey {
+		if report.SanitizedKey != "" {
+			file.ObjectKey = report.SanitizedKey
+			file.ContentType = objectContentType(report.SanitizedKey)
+		}
+		file.ThumbnailKey = report.ThumbnailKey
+		file.Width = report.Width
+		file.Height = report.Height
+	}
+	return file, true
+}
+
+// StepLabel is the "label" or "title" of a step in the flow default locale,
+// falling back to its step_id
+func StepLabel(flow *domain.Flow, step domain.StepConfig) string {
+	for _, config := range []map[string]interface{}{step.Config, step.BaseConfig} {
+		for _, field := range []string{"label", "title"} {
+			text, _ := config[field].(string)
+			if key, ok := strings.CutPrefix(text, TranslationPrefix); ok {
+				text, _ = Translate(key, []string{flow.DefaultLocale}, flow.Translations)
+			}
+			if text != "" {
+				return text
+			}
+		}
+	}
+	return step.StepID
+}
+
+// objectContentType derives the MIME type from the extension of the generated
+// object name
+func objectContentType(objectKey string) string {
+	ext := strings.TrimPrefix(path.Ext(objectKey), ".")
+	for contentType, e := range AllowedUploadTypes {
+		if e == ext {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/google/uuid"
+)
+
+type IssueSeverity string
+
+const (
+	SeverityError   IssueSeverity = "error"   // Flow cannot be saved
+	SeverityWarning IssueSeverity = "warning" // Flow works but something is missing
+)
+
+type FlowIssue struct {
+	Severity IssueSeverity `json:"severity"`
+	StepID   string        `json:"step_id,omitempty"`
+	Locale   string        `json:"locale,omitempty"`
+	Message  string        `json:"message"`
+}
+
+// HasErrors reports whether any issue blocks saving the flow
+func HasErrors(issues []FlowIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFlow checks the structure of a flow and reports translation keys that
+// are missing for any of the locales the flow declares. Templates are looked up
+// by ID to include their string tables.
+func ValidateFlow(flow *domain.Flow, templates map[uuid.UUID]domain.StepTemplate) []FlowIssue {
+	issues := []FlowIssue{}
+
+	if flow.DefaultLocale != "" {
+		if _, err := NormalizeLocale(flow.DefaultLocale); err != nil {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: err.Error()})
+		}
+	}
+	for locale := range flow.Translations {
+		if _, err := NormalizeLocale(locale); err != nil {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Locale: locale, Message: err.Error()})
+		}
+	}
+
+	// Structure
+	seen := make(map[string]bool)
+	for i, step := range flow.StepsConfiguration {
+		if step.StepID == "" {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: fmt.Sprintf("step %d has no step_id", i+1)})
+		} else if seen[step.StepID] {
+			issues = append(issues, FlowIssue{Severity: SeverityError, StepID: step.StepID, Message: "duplicated step_id"})
+		}
+		seen[step.StepID] = true
+
+		if step.Type == "" {
+			issues = append(issues, FlowIssue{Severity: SeverityError, StepID: step.StepID, Message: "step has no type"})
+		}
+		if step.TemplateID != nil {
+			if _, ok := templates[*step.TemplateID]; !ok {
+				issues = append(issues, FlowIssue{Severity: SeverityError, StepID: step.StepID, Message: "template not found"})
+			}
+		}
+	}
+
+	issues = append(issues, ValidateDecisionPolicy(flow.DecisionPolicy)...)
+	issues = append(issues, ValidateReviewPolicy(flow.ReviewPolicy)...)
+
+	// Translations: every declared locale must resolve every referenced key,
+	// falling back the way sessions do (language, flow default, "en")
+	locales := declaredLocales(flow)
+	for _, step := range flow.StepsConfiguration {
+		tables := []domain.Translations{flow.Translations}
+		if step.TemplateID != nil {
+			if tpl, ok := templates[*step.TemplateID]; ok {
+				tables = append(tables, tpl.Translations)
+			}
+		}
+
+		keys := TranslationKeys(map[string]interface{}{"config": step.Config, "base_config": step.BaseConfig})
+		for _, locale := range locales {
+			for _, key := range keys {
+				if _, ok := Translate(key, LocaleChain(locale, flow.DefaultLocale), tables...); !ok {
+					issues = append(issues, FlowIssue{
+						Severity: SeverityWarning,
+						StepID:   step.StepID,
+						Locale:   locale,
+						Message:  fmt.Sprintf("missing translation for %q", key),
+					})
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+func declaredLocales(flow *domain.Flow) []string {
+	set := make(map[string]bool)
+	if flow.DefaultLocale != "" {
+		set[flow.DefaultLocale] = true
+	}
+	for locale := range flow.Translations {
+		set[locale] = true
+	}
+	locales := make([]string, 0, len(set))
+	for l := range set {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// TranslationPrefix marks a step config string as a key in the string tables,
+// e.g. {"title": "$t:welcome.title"}
+const TranslationPrefix = "$t:"
+
+const FallbackLocale = "en"
+
+var localePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+// NormalizeLocale accepts "es-mx", "es_MX" or "es-MX" and returns "es-MX"
+func NormalizeLocale(raw string) (string, error) {
+	l := strings.ReplaceAll(strings.TrimSpace(raw), "_", "-")
+	if parts := strings.SplitN(l, "-", 2); len(parts) == 2 {
+		l = strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1])
+	} else {
+		l = strings.ToLower(l)
+	}
+	if !localePattern.MatchString(l) {
+		return "", fmt.Errorf("invalid locale %q", raw)
+	}
+	return l, nil
+}
+
+// LocaleChain returns the lookup order for a session locale:
+// "pt-BR" -> "pt" -> flow default -> "en"
+func LocaleChain(locale, defaultLocale string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	add := func(l string) {
+		if l != "" && !seen[l] {
+			seen[l] = true
+			chain = append(chain, l)
+		}
+	}
+	for _, l := range []string{locale, defaultLocale, FallbackLocale} {
+		add(l)
+		if i := strings.Index(l, "-"); i > 0 {
+			add(l[:i])
+		}
+	}
+	return chain
+}
+
+// Translate looks a key up following the locale chain. Tables are searched in
+// order for each locale (flow first, then template).
+func Translate(key string, chain []string, tables ...domain.Translations) (string, bool) {
+	for _, locale := range chain {
+		for _, table := range tables {
+			if text, ok := table[locale][key]; ok {
+				return text, true
+			}
+		}
+	}
+	return "", false
+}
+
+// LocalizeConfig returns a copy of a step config with every "$t:key" value
+// resolved. Unknown keys are replaced by the key itself.
+func LocalizeConfig(config map[string]interface{}, chain []string, tables ...domain.Translations) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	return localizeValue(config, chain, tables).(map[string]interface{})
+}
+
+func localizeValue(v interface{}, chain []string, tables []domain.Translations) interface{} {
+	switch val := v.(type) {
+	case string:
+		if key, ok := strings.CutPrefix(val, TranslationPrefix); ok {
+			if text, found := Translate(key, chain, tables...); found {
+				return text
+			}
+			return key
+		}
+		return val
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = localizeValue(item, chain, tables)
+		}
+		return out
+	case domain.JSONB:
+		return localizeValue(map[string]interface{}(val), chain, tables)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = localizeValue(item, chain, tables)
+		}
+		return out
+	}
+	return v
+}
+
+// TranslationKeys lists the "$t:" keys referenced anywhere in a step config
+func TranslationKeys(config map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			if key, ok := strings.CutPrefix(val, TranslationPrefix); ok {
+				seen[key] = true
+			}
+		case map[string]interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		case domain.JSONB:
+			walk(map[string]interface{}(val))
+		case []interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		}
+	}
+	walk(config)
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+)
+
+// Image limits checked after upload
+const (
+	MinImageWidth   = 320
+	MinImageHeight  = 240
+	MaxImagePixels  = 40_000_000 // Guards against decompression bombs
+	ThumbnailSize   = 320        // Longest side in px
+	sanitizeQuality = 92
+)
+
+// ImageReport is what the pipeline records in the session results for each upload
+type ImageReport struct {
+	Key              string    `json:"key"`
+	Format           string    `json:"format"` // Detected from magic bytes: jpeg, png, webp
+	StoredFormat     string    `json:"stored_format"`
+	Width            int       `json:"width"`
+	Height           int       `json:"height"`
+	SizeBytes        int       `json:"size_bytes"`
+	MetadataStripped bool      `json:"metadata_stripped"`
+	ThumbnailKey     string    `json:"thumbnail_key"`
+	ProcessedAt      time.Time `json:"processed_at"`
+	PerceptualHash   string    `json:"perceptual_hash"` // dHash, finds the same picture in other sessions
+	UploadSHA256     string    `json:"upload_sha256"`   // Of the file as uploaded, before sanitizing
+	SanitizedKey     string    `json:"sanitized_key"`   // Metadata free copy the checks and reviewers read
+
+	Quality       QualityMetrics `json:"quality"`
+	QualityIssues []string       `json:"quality_issues,omitempty"` // Set when accepted after exhausting retries
+}
+
+// ImageValidationError means the uploaded file is not an acceptable image
+type ImageValidationError struct {
+	Reason string
+}
+
+func (e *ImageValidationError) Error() string {
+	return e.Reason
+}
+
+// ImageProcessor inspects files once the browser has uploaded them to blob storage
+type ImageProcessor struct {
+	Storage *StorageService
+}
+
+func NewImageProcessor(storage *StorageService) *ImageProcessor {
+	return &ImageProcessor{Storage: storage}
+}
+
+// DetectImageFormat checks the magic bytes, ignoring the declared content type
+func DetectImageFormat(data []byte) (string, bool) {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return "jpeg", true
+	case len(data) >= 8 && bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")):
+		return "png", true
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp", true
+	}
+	return "", false
+}
+
+// Process validates an uploaded image and stores a metadata free copy (EXIF/GPS
+// removed, orientation applied) and a thumbnail next to it. The upload itself
+// is left as it is.
+func (p *ImageProcessor) Process(ctx context.Context, objectKey string) (*ImageReport, error) {
+	// 1. Fetch
+	data, err := p.Storage.GetObject(ctx, objectKey, MaxUploadSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, &ImageValidationError{Reason: "file is empty"}
+	}
+
+	// 2. Magic bytes
+	format, ok := DetectImageFormat(data)
+	if !ok {
+		return nil, &ImageValidationError{Reason: "file is not a supported image"}
+	}
+
+	// 3. Dimensions (header only, before allocating the full image)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ImageValidationError{Reason: "image header is corrupt"}
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, &ImageValidationError{Reason: "image resolution is too large"}
+	}
+	if max(cfg.Width, cfg.Height) < MinImageWidth || min(cfg.Width, cfg.Height) < MinImageHeight {
+		return nil, &ImageValidationError{Reason: fmt.Sprintf("image must be at least %dx%d", MinImageWidth, MinImageHeight)}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ImageValidationError{Reason: "image data is corrupt"}
+	}
+
+	// 4. Strip metadata: re-encoding only keeps pixels. Orientation is applied first
+	// so the image does not end up rotated once the EXIF tag is gone.
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	sanitized, storedFormat, contentType, err := encodeSanitized(img, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode image: %w", err)
+	}
+	sanitizedKey := SanitizedKey(objectKey, contentType)
+	if err := p.Storage.PutObject(ctx, sanitizedKey, bytes.NewReader(sanitized), int64(len(sanitized)), contentType); err != nil {
+		return nil, err
+	}
+
+	// 5. Thumbnail for the reviewer UI
+	thumb, err := encodeThumbnail(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create thumbnail: %w", err)
+	}
+	thumbKey := ThumbnailKey(objectKey)
+	if err := p.Storage.PutObject(ctx, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	uploaded := sha256.Sum256(data)
+	return &ImageReport{
+		Key:              objectKey,
+		Format:           format,
+		StoredFormat:     storedFormat,
+		Width:            b.Dx(),
+		Height:           b.Dy(),
+		SizeBytes:        len(sanitized),
+		MetadataStripped: true,
+		ThumbnailKey:     thumbKey,
+		ProcessedAt:      time.Now(),
+		PerceptualHash:   PerceptualHash(img),
+		UploadSHA256:     hex.EncodeToString(uploaded[:]),
+		SanitizedKey:     sanitizedKey,
+		Quality:          AnalyzeQuality(img),
+	}, nil
+}
+
+// ThumbnailKey returns the object key of the thumbnail generated for an upload
+func ThumbnailKey(objectKey string) string {
+	return strings.TrimSuffix(objectKey, path.Ext(objectKey)) + "_thumb.jpg"
+}
+
+// SanitizedKey returns the object key of the metadata free copy of an upload,
+// with the extension of the format it was re-encoded to
+func SanitizedKey(objectKey, contentType string) string {
+	return strings.TrimSuffix(objectKey, path.Ext(objectKey)) + "_clean." + AllowedUploadTypes[contentType]
+}
+
+// ProcessedObjectKey is the object the checks read for an uploaded field: the
+// sanitized copy when the upload report of the field is for objectKey, the
+// upload itself otherwise (sessions processed before copies were kept apart)
+func ProcessedObjectKey(uploads map[string]interface{}, field, objectKey string) string {
+	var report ImageReport
+	if convertCollected(uploads[field], &report) && report.Key == objectKey && report.SanitizedKey != "" {
+		return report.SanitizedKey
+	}
+	return objectKey
+}
+
+// encodeSanitized keeps JPEG as JPEG and stores everything else as PNG, since
+// the standard library has no WebP encoder.
+func encodeSanitized(img image.Image, format string) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: sanitizeQuality})
+		return buf.Bytes(), "jpeg", "image/jpeg", err
+	}
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "png", "image/png", err
+}
+
+func encodeThumbnail(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w >= h && w > ThumbnailSize {
+		w, h = ThumbnailSize, h*ThumbnailSize/w
+	} else if h > w && h > ThumbnailSize {
+		w, h = w*ThumbnailSize/h, ThumbnailSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MRZ formats defined by ICAO 9303
+const (
+	MRZFormatTD1 = "TD1" // ID cards: 3 lines of 30
+	MRZFormatTD2 = "TD2" // Older ID cards / visas: 2 lines of 36
+	MRZFormatTD3 = "TD3" // Passports: 2 lines of 44
+)
+
+// MRZDocument is the structured data read from a machine readable zone
+type MRZDocument struct {
+	Format         string `json:"format"`
+	DocumentType   string `json:"document_type"` // e.g. P, I, ID, AC
+	IssuingCountry string `json:"issuing_country"`
+	DocumentNumber string `json:"document_number"`
+	Surname        string `json:"surname"`
+	GivenNames     string `json:"given_names"`
+	Nationality    string `json:"nationality"`
+	DateOfBirth    string `json:"date_of_birth"` // YYYY-MM-DD
+	Sex            string `json:"sex"`           // M, F or X
+	ExpiryDate     string `json:"expiry_date"`   // YYYY-MM-DD
+	OptionalData   string `json:"optional_data,omitempty"`
+	Expired        bool   `json:"expired"`
+}
+
+// MRZError lists every check that failed so the user can fix the input at once
+type MRZError struct {
+	Reasons []string
+}
+
+func (e *MRZError) Error() string {
+	return "invalid MRZ: " + strings.Join(e.Reasons, "; ")
+}
+
+// ParseMRZ parses and validates TD1, TD2 or TD3 MRZ text (OCR output or typed
+// by the user). Lines may be separated by newlines or concatenated. All check
+// digits are verified; an expired document is parsed but marked as Expired.
+func ParseMRZ(text string, now time.Time) (*MRZDocument, error) {
+	lines := normalizeMRZ(text)
+
+	var doc *MRZDocument
+	var reasons []string
+	switch {
+	case len(lines) == 3 && allLen(lines, 30):
+		doc, reasons = parseTD1(lines)
+	case len(lines) == 2 && allLen(lines, 36):
+		doc, reasons = parseTD2(lines)
+	case len(lines) == 2 && allLen(lines, 44):
+		doc, reasons = parseTD3(lines)
+	default:
+		return nil, &MRZError{Reasons: []string{"MRZ must be 3 lines of 30, 2 lines of 36 or 2 lines of 44 characters"}}
+	}
+
+	dob, err := mrzDate(doc.DateOfBirth, false, now)
+	if err != nil {
+		reasons = append(reasons, "date of birth is invalid")
+	}
+	expiry, err := mrzDate(doc.ExpiryDate, true, now)
+	if err != nil {
+		reasons = append(reasons, "expiry date is invalid")
+	}
+	if len(reasons) > 0 {
+		return nil, &MRZError{Reasons: reasons}
+	}
+
+	doc.DateOfBirth = dob.Format("2006-01-02")
+	doc.ExpiryDate = expiry.Format("2006-01-02")
+	doc.Expired = !now.Before(expiry.AddDate(0, 0, 1))
+	return doc, nil
+}
+
+func normalizeMRZ(text string) []string {
+	text = strings.ToUpper(strings.ReplaceAll(text, "\r", ""))
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.Join(strings.Fields(l), "")
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	// Single line input: split by the known total lengths
+	if len(lines) == 1 {
+		l := lines[0]
+		switch len(l) {
+		case 90:
+			return []string{l[:30], l[30:60], l[60:]}
+		case 72:
+			return []string{l[:36], l[36:]}
+		case 88:
+			return []string{l[:44], l[44:]}
+		}
+	}
+	return lines
+}
+
+func allLen(lines []string, n int) bool {
+	for _, l := range lines {
+		if len(l) != n {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTD1(l []string) (*MRZDocument, []string) {
+	var reasons []string
+	doc := &MRZDocument{
+		Format:         MRZFormatTD1,
+		DocumentType:   mrzField(l[0][0:2]),
+		IssuingCountry: mrzField(l[0][2:5]),
+		DateOfBirth:    l[1][0:6],
+		Sex:            mrzSex(l[1][7]),
+		ExpiryDate:     l[1][8:14],
+		Nationality:    mrzField(l[1][15:18]),
+	}
+
+	// Document numbers longer than 9 characters continue in the optional
+	// data, with their check digit as the last character before the filler.
+	number, optional := l[0][5:14], l[0][15:30]
+	numberCheck := l[0][14]
+	if numberCheck == '<' {
+		ext := strings.TrimRight(optional, "<")
+		if ext == "" {
+			reasons = append(reasons, "document number check digit is missing")
+		} else {
+			number += ext[:len(ext)-1]
+			numberCheck = ext[len(ext)-1]
+			optional = ""
+		}
+	}
+	doc.DocumentNumber = mrzField(number)
+	doc.OptionalData = mrzField(optional + l[1][18:29])
+	doc.Surname, doc.GivenNames = mrzNames(l[2])
+
+	reasons = checkDigit(reasons, "document number", number, numberCheck)
+	reasons = checkDigit(reasons, "date of birth", l[1][0:6], l[1][6])
+	reasons = checkDigit(reasons, "expiry date", l[1][8:14], l[1][14])
+	reasons = checkDigit(reasons, "composite", l[0][5:30]+l[1][0:7]+l[1][8:15]+l[1][18:29], l[1][29])
+	return doc, reasons
+}
+
+func parseTD2(l []string) (*MRZDocument, []string) {
+	doc, reasons := parseTwoLine(l, MRZFormatTD2, 35)
+	reasons = checkDigit(reasons, "composite", l[1][0:10]+l[1][13:20]+l[1][21:35], l[1][35])
+	return doc, reasons
+}
+
+func parseTD3(l []string) (*MRZDocument, []string) {
+	doc, reasons := parseTwoLine(l, MRZFormatTD3, 42)
+	// The personal number check digit may be filler when there is no personal number
+	if l[1][42] != '<' || strings.Trim(l[1][28:42], "<") != "" {
+		reasons = checkDigit(reasons, "personal number", l[1][28:42], l[1][42])
+	}
+	reasons = checkDigit(reasons, "composite", l[1][0:10]+l[1][13:20]+l[1][21:43], l[1][43])
+	return doc, reasons
+}
+
+// parseTwoLine reads the fields TD2 and TD3 share; optionalEnd is where the
+// optional/personal data of the second line stops.
+func parseTwoLine(l []string, format string, optionalEnd int) (*MRZDocument, []string) {
+	doc := &MRZDocument{
+		Format:         format,
+		DocumentType:   mrzField(l[0][0:2]),
+		IssuingCountry: mrzField(l[0][2:5]),
+		DocumentNumber: mrzField(l[1][0:9]),
+		Nationality:    mrzField(l[1][10:13]),
+		DateOfBirth:    l[1][13:19],
+		Sex:            mrzSex(l[1][20]),
+		ExpiryDate:     l[1][21:27],
+		OptionalData:   mrzField(l[1][28:optionalEnd]),
+	}
+	doc.Surname, doc.GivenNames = mrzNames(l[0][5:])
+
+	var reasons []string
+	reasons = checkDigit(reasons, "document number", l[1][0:9], l[1][9])
+	reasons = checkDigit(reasons, "date of birth", l[1][13:19], l[1][19])
+	reasons = checkDigit(reasons, "expiry date", l[1][21:27], l[1][27])
+	return doc, reasons
+}
+
+// MRZCheckDigit computes the ICAO 9303 check digit (weights 7, 3, 1)
+func MRZCheckDigit(s string) (byte, bool) {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		var v int
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		case c == '<':
+			v = 0
+		default:
+			return 0, false
+		}
+		sum += v * weights[i%3]
+	}
+	return byte('0' + sum%10), true
+}
+
+func checkDigit(reasons []string, name, data string, digit byte) []string {
+	expected, ok := MRZCheckDigit(data)
+	if !ok {
+		return append(reasons, fmt.Sprintf("%s contains invalid characters", name))
+	}
+	if digit == '<' {
+		digit = '0'
+	}
+	if digit != expected {
+		return append(reasons, fmt.Sprintf("%s check digit does not match", name))
+	}
+	return reasons
+}
+
+func mrzField(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "<", " "))
+}
+
+func mrzNames(s string) (string, string) {
+	surname, given, _ := strings.Cut(strings.TrimRight(s, "<"), "<<")
+	return mrzField(surname), strings.Join(strings.Fields(mrzField(given)), " ")
+}
+
+func mrzSex(c byte) string {
+	switch c {
+	case 'M', 'F':
+		return string(c)
+	}
+	return "X"
+}
+
+// mrzDate resolves the century of a YYMMDD date. Birth dates can't be in the
+// future; expiry dates are assumed to be within the next 50 years.
+func mrzDate(yymmdd string, expiry bool, now time.Time) (time.Time, error) {
+	t, err := time.Parse("060102", yymmdd)
+	if err != nil {
+		return time.Time{}, err
+	}
+	year := 2000 + t.Year()%100
+	if expiry {
+		if year > now.Year()+50 {
+			year -= 100
+		}
+	} else if year > now.Year() {
+		year -= 100
+	}
+	return time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
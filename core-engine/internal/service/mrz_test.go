@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Specimens from ICAO 9303 parts 4 (TD3), 5 (TD1) and 6 (TD2)
+const (
+	specimenTD1 = "I<UTOD231458907<<<<<<<<<<<<<<<\n7408122F1204159UTO<<<<<<<<<<<6\nERIKSSON<<ANNA<MARIA<<<<<<<<<<"
+	specimenTD2 = "I<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<\nD231458907UTO7408122F1204159<<<<<<<6"
+	specimenTD3 = "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10"
+)
+
+func TestMRZCheckDigit(t *testing.T) {
+	tests := []struct {
+		data string
+		want byte
+	}{
+		{"L898902C3", '6'},
+		{"D23145890", '7'},
+		{"740812", '2'},
+		{"120415", '9'},
+		{"ZE184226B<<<<<", '1'},
+		{"<<<<<<", '0'},
+	}
+	for _, tt := range tests {
+		got, ok := MRZCheckDigit(tt.data)
+		if !ok || got != tt.want {
+			t.Errorf("MRZCheckDigit(%q) = %q, %v, want %q", tt.data, got, ok, tt.want)
+		}
+	}
+	if _, ok := MRZCheckDigit("L8989-2C3"); ok {
+		t.Error("MRZCheckDigit accepted an invalid character")
+	}
+}
+
+func TestParseMRZSpecimens(t *testing.T) {
+	now := time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		text string
+		want MRZDocument
+	}{
+		{"TD1", specimenTD1, MRZDocument{
+			Format: MRZFormatTD1, DocumentType: "I", IssuingCountry: "UTO", DocumentNumber: "D23145890",
+			Surname: "ERIKSSON", GivenNames: "ANNA MARIA", Nationality: "UTO",
+			DateOfBirth: "1974-08-12", Sex: "F", ExpiryDate: "2012-04-15",
+		}},
+		{"TD2", specimenTD2, MRZDocument{
+			Format: MRZFormatTD2, DocumentType: "I", IssuingCountry: "UTO", DocumentNumber: "D23145890",
+			Surname: "ERIKSSON", GivenNames: "ANNA MARIA", Nationality: "UTO",
+			DateOfBirth: "1974-08-12", Sex: "F", ExpiryDate: "2012-04-15",
+		}},
+		{"TD3", specimenTD3, MRZDocument{
+			Format: MRZFormatTD3, DocumentType: "P", IssuingCountry: "UTO", DocumentNumber: "L898902C3",
+			Surname: "ERIKSSON", GivenNames: "ANNA MARIA", Nationality: "UTO",
+			DateOfBirth: "1974-08-12", Sex: "F", ExpiryDate: "2012-04-15", OptionalData: "ZE184226B",
+		}},
+		{"TD3 on one line, lower case", strings.ToLower(strings.ReplaceAll(specimenTD3, "\n", " ")), MRZDocument{
+			Format: MRZFormatTD3, DocumentType: "P", IssuingCountry: "UTO", DocumentNumber: "L898902C3",
+			Surname: "ERIKSSON", GivenNames: "ANNA MARIA", Nationality: "UTO",
+			DateOfBirth: "1974-08-12", Sex: "F", ExpiryDate: "2012-04-15", OptionalData: "ZE184226B",
+		}},
+		// Document number of 12 characters continued in the optional data (part 5, 4.2.4)
+		{"TD1 long document number", "I<UTOD23145890<7349<<<<<<<<<<<\n3407127M9507122UTO<<<<<<<<<<<2\nSTEVENSON<<PETER<JOHN<<<<<<<<<", MRZDocument{
+			Format: MRZFormatTD1, DocumentType: "I", IssuingCountry: "UTO", DocumentNumber: "D23145890734",
+			Surname: "STEVENSON", GivenNames: "PETER JOHN", Nationality: "UTO",
+			DateOfBirth: "1934-07-12", Sex: "M", ExpiryDate: "1995-07-12", Expired: true,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMRZ(tt.text, now)
+			if err != nil {
+				t.Fatalf("ParseMRZ: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseMRZ = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMRZExpired(t *testing.T) {
+	for _, tt := range []struct {
+		now  time.Time
+		want bool
+	}{
+		{time.Date(2012, 4, 15, 23, 59, 0, 0, time.UTC), false}, // Valid through the expiry day
+		{time.Date(2012, 4, 16, 0, 0, 0, 0, time.UTC), true},
+	} {
+		doc, err := ParseMRZ(specimenTD3, tt.now)
+		if err != nil {
+			t.Fatalf("ParseMRZ: %v", err)
+		}
+		if doc.Expired != tt.want {
+			t.Errorf("Expired at %s = %v, want %v", tt.now, doc.Expired, tt.want)
+		}
+	}
+}
+
+func TestParseMRZInvalid(t *testing.T) {
+	now := time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"wrong length", "P<UTOERIKSSON<<ANNA<MARIA\nL898902C36UTO7408122F1204159", []string{"MRZ must be 3 lines of 30, 2 lines of 36 or 2 lines of 44 characters"}},
+		{"TD3 document number", strings.Replace(specimenTD3, "L898902C36", "L898902C35", 1), []string{"document number check digit does not match", "composite check digit does not match"}},
+		{"TD3 date of birth", strings.Replace(specimenTD3, "7408122F", "7408132F", 1), []string{"date of birth check digit does not match", "composite check digit does not match"}},
+		{"TD3 personal number", strings.Replace(specimenTD3, "<<<<<10", "<<<<<20", 1), []string{"personal number check digit does not match", "composite check digit does not match"}},
+		{"TD3 composite only", strings.Replace(specimenTD3, "<<<<<10", "<<<<<11", 1), []string{"composite check digit does not match"}},
+		{"TD2 composite only", strings.Replace(specimenTD2, "<<<<<<<6", "<<<<<<<5", 1), []string{"composite check digit does not match"}},
+		{"TD1 expiry date", strings.Replace(specimenTD1, "1204159", "1204158", 1), []string{"expiry date check digit does not match", "composite check digit does not match"}},
+		{"TD1 composite only", strings.Replace(specimenTD1, "<<<<<<<<<<<6", "<<<<<<<<<<<7", 1), []string{"composite check digit does not match"}},
+		{"impossible date", strings.Replace(specimenTD3, "7408122", "7413324", 1), []string{"date of birth is invalid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMRZ(tt.text, now)
+			var mrzErr *MRZError
+			if !errors.As(err, &mrzErr) {
+				t.Fatalf("ParseMRZ error = %v, want an MRZError", err)
+			}
+			if !reflect.DeepEqual(mrzErr.Reasons, tt.want) {
+				t.Errorf("Reasons = %q, want %q", mrzErr.Reasons, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OCRRequest is a document image sent to a provider
+type OCRRequest struct {
+	Image       []byte
+	ContentType string
+	Side        string // front/back, when the step knows it
+	// SHA-256 of the file as uploaded; Image is the sanitized copy
+	UploadSHA256 string
+}
+
+// OCRField is a single value read from the document
+type OCRField struct {
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"` // 0-1
+}
+
+// OCRResult is the normalized output of a provider. Raw keeps the provider
+// response untouched so it can be audited later.
+type OCRResult struct {
+	Fields map[string]OCRField `json:"fields"` // e.g. document_number, surname, date_of_birth
+	MRZ    string              `json:"mrz,omitempty"`
+	Raw    json.RawMessage     `json:"raw,omitempty"`
+}
+
+// OCRProvider extracts text fields from a document image
+type OCRProvider interface {
+	Name() string
+	Extract(ctx context.Context, req OCRRequest) (*OCRResult, error)
+}
+
+// OCRRun is what gets recorded in the session results for each provider call
+type OCRRun struct {
+	Provider  string              `json:"provider"`
+	Field     string              `json:"field"` // Upload field the image came from
+	LatencyMS int64               `json:"latency_ms"`
+	Fields    map[string]OCRField `json:"fields,omitempty"`
+	MRZ       string              `json:"mrz,omitempty"`
+	Raw       json.RawMessage     `json:"raw,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	RanAt     time.Time           `json:"ran_at"`
+}
+
+// OCRRegistry holds the configured providers. Steps pick one by name with
+// config.ocr_provider, otherwise the default provider (if any) is used.
+type OCRRegistry struct {
+	providers   map[string]OCRProvider
+	defaultName string
+}
+
+func NewOCRRegistry(defaultName string) *OCRRegistry {
+	return &OCRRegistry{providers: map[string]OCRProvider{}, defaultName: defaultName}
+}
+
+func (r *OCRRegistry) Register(p OCRProvider) {
+	r.providers[p.Name()] = p
+}
+
+// ForStep returns the provider selected by the step config, or nil when OCR
+// is not enabled for the step.
+func (r *OCRRegistry) ForStep(config map[string]interface{}) (OCRProvider, error) {
+	name := r.defaultName
+	if v, ok := config["ocr_provider"].(string); ok {
+		name = v
+	}
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown OCR provider %q", name)
+	}
+	return p, nil
+}
+
+// RunOCR calls the provider and records the outcome, including failures
+func RunOCR(ctx context.Context, p OCRProvider, field string, req OCRRequest) OCRRun {
+	start := time.Now()
+	result, err := p.Extract(ctx, req)
+	run := OCRRun{
+		Provider:  p.Name(),
+		Field:     field,
+		LatencyMS: time.Since(start).Milliseconds(),
+		RanAt:     start,
+	}
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.Fields = result.Fields
+	run.MRZ = result.MRZ
+	run.Raw = result.Raw
+	return run
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalOCRProviderName is the name steps use to select the fixture provider
+const LocalOCRProviderName = "local"
+
+// LocalOCRProvider is a deterministic provider for development and tests.
+// If FixturesDir contains "<sha256 of the uploaded file>.json" (an OCRResult)
+// that file is returned; any other image reads nothing. The hash is the one of
+// the file as uploaded, not of the sanitized copy the pipeline stores.
+type LocalOCRProvider struct {
+	FixturesDir string
+}
+
+func NewLocalOCRProvider(fixturesDir string) *LocalOCRProvider {
+	return &LocalOCRProvider{FixturesDir: fixturesDir}
+}
+
+func (p *LocalOCRProvider) Name() string {
+	return LocalOCRProviderName
+}
+
+func (p *LocalOCRProvider) Extract(ctx context.Context, req OCRRequest) (*OCRResult, error) {
+	hash := req.UploadSHA256
+	if hash == "" {
+		sum := sha256.Sum256(req.Image)
+		hash = hex.EncodeToString(sum[:])
+	}
+
+	if p.FixturesDir != "" {
+		data, err := os.ReadFile(filepath.Join(p.FixturesDir, hash+".json"))
+		if err == nil {
+			var result OCRResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				return nil, fmt.Errorf("invalid OCR fixture %s: %w", hash, err)
+			}
+			result.Raw = data
+			return &result, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return noResult(hash)
+}
+
+// noResult is an empty read: no fields and no MRZ, nothing to fill the
+// document with
+func noResult(hash string) (*OCRResult, error) {
+	raw, err := json.Marshal(map[string]interface{}{"provider": LocalOCRProviderName, "image_sha256": hash, "fixture": false})
+	if err != nil {
+		return nil, err
+	}
+	return &OCRResult{Fields: map[string]OCRField{}, Raw: raw}, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// QualityMetrics are computed on a grayscale copy of the upload
+type QualityMetrics struct {
+	BlurScore  float64 `json:"blur_score"`  // Variance of the Laplacian, lower is blurrier
+	Brightness float64 `json:"brightness"`  // Mean luminance 0-255
+	GlareRatio float64 `json:"glare_ratio"` // Share of (almost) saturated pixels 0-1
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+}
+
+// QualityThresholds come from the step config ("quality" object)
+type QualityThresholds struct {
+	Enabled       bool    `json:"enabled"`
+	MinBlurScore  float64 `json:"min_blur_score"`
+	MinBrightness float64 `json:"min_brightness"`
+	MaxBrightness float64 `json:"max_brightness"`
+	MaxGlareRatio float64 `json:"max_glare_ratio"`
+	MinWidth      int     `json:"min_width"`
+	MinHeight     int     `json:"min_height"`
+	MaxAttempts   int     `json:"max_attempts"` // After this many retries the capture is accepted and flagged
+}
+
+// Metrics are computed at this resolution so scores don't depend on the camera
+const qualityAnalysisSize = 1024
+
+const glareLuminance = 250
+
+// AnalyzeQuality computes blur, brightness, glare and resolution metrics
+func AnalyzeQuality(img image.Image) QualityMetrics {
+	b := img.Bounds()
+	gray := toAnalysisGray(img)
+
+	gb := gray.Bounds()
+	w, h := gb.Dx(), gb.Dy()
+
+	// Brightness & glare
+	var sum float64
+	var glare int
+	for y := 0; y < h; y++ {
+		row := gray.Pix[y*gray.Stride : y*gray.Stride+w]
+		for _, v := range row {
+			sum += float64(v)
+			if v >= glareLuminance {
+				glare++
+			}
+		}
+	}
+	total := float64(w * h)
+
+	// Laplacian variance (4-neighbour kernel) on the inner pixels
+	var lapSum, lapSq float64
+	var n float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			c := float64(gray.Pix[y*gray.Stride+x])
+			lap := float64(gray.Pix[(y-1)*gray.Stride+x]) +
+				float64(gray.Pix[(y+1)*gray.Stride+x]) +
+				float64(gray.Pix[y*gray.Stride+x-1]) +
+				float64(gray.Pix[y*gray.Stride+x+1]) - 4*c
+			lapSum += lap
+			lapSq += lap * lap
+			n++
+		}
+	}
+	variance := 0.0
+	if n > 0 {
+		mean := lapSum / n
+		variance = lapSq/n - mean*mean
+	}
+
+	return QualityMetrics{
+		BlurScore:  round2(variance),
+		Brightness: round2(sum / total),
+		GlareRatio: math.Round(float64(glare)/total*10000) / 10000,
+		Width:      b.Dx(),
+		Height:     b.Dy(),
+	}
+}
+
+func toAnalysisGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if longest := max(w, h); longest > qualityAnalysisSize {
+		w = w * qualityAnalysisSize / longest
+		h = h * qualityAnalysisSize / longest
+	}
+	gray := image.NewGray(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.ApproxBiLinear.Scale(gray, gray.Bounds(), img, b, draw.Src, nil)
+	return gray
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// DefaultQualityThresholds returns the thresholds for capture step types, or
+// disabled thresholds for any other step.
+func DefaultQualityThresholds(stepType string) QualityThresholds {
+	switch stepType {
+	case "document_scan", "document_capture":
+		return QualityThresholds{Enabled: true, MinBlurScore: 100, MinBrightness: 60, MaxBrightness: 220, MaxGlareRatio: 0.05, MinWidth: 640, MinHeight: 400, MaxAttempts: 3}
+	case "selfie_capture", "selfie":
+		return QualityThresholds{Enabled: true, MinBlurScore: 60, MinBrightness: 50, MaxBrightness: 230, MaxGlareRatio: 0.1, MinWidth: 480, MinHeight: 480, MaxAttempts: 3}
+	}
+	return QualityThresholds{}
+}
+
+// QualityThresholdsFor merges the step config "quality" object over the defaults
+func QualityThresholdsFor(stepType string, config map[string]interface{}) QualityThresholds {
+	t := DefaultQualityThresholds(stepType)
+	q, ok := config["quality"].(map[string]interface{})
+	if !ok {
+		return t
+	}
+	if v, ok := q["enabled"].(bool); ok {
+		t.Enabled = v
+	}
+	num := func(key string, dst *float64) {
+		if v, ok := q[key].(float64); ok {
+			*dst = v
+		}
+	}
+	integer := func(key string, dst *int) {
+		if v, ok := q[key].(float64); ok {
+			*dst = int(v)
+		}
+	}
+	num("min_blur_score", &t.MinBlurScore)
+	num("min_brightness", &t.MinBrightness)
+	num("max_brightness", &t.MaxBrightness)
+	num("max_glare_ratio", &t.MaxGlareRatio)
+	integer("min_width", &t.MinWidth)
+	integer("min_height", &t.MinHeight)
+	integer("max_attempts", &t.MaxAttempts)
+	return t
+}
+
+// QualityIssues returns the user facing reasons why a capture should be retaken.
+// A zero threshold disables that check.
+func QualityIssues(m QualityMetrics, t QualityThresholds) []string {
+	if !t.Enabled {
+		return nil
+	}
+	var issues []string
+	if t.MinBlurScore > 0 && m.BlurScore < t.MinBlurScore {
+		issues = append(issues, "image is blurry")
+	}
+	if t.MinBrightness > 0 && m.Brightness < t.MinBrightness {
+		issues = append(issues, "image is too dark")
+	}
+	if t.MaxBrightness > 0 && m.Brightness > t.MaxBrightness {
+		issues = append(issues, "image is overexposed")
+	}
+	if t.MaxGlareRatio > 0 && m.GlareRatio > t.MaxGlareRatio {
+		issues = append(issues, "image has glare")
+	}
+	// Width/height apply to the long/short side so portrait captures are not penalized
+	if (t.MinWidth > 0 && max(m.Width, m.Height) < t.MinWidth) || (t.MinHeight > 0 && min(m.Width, m.Height) < t.MinHeight) {
+		issues = append(issues, fmt.Sprintf("image resolution must be at least %dx%d", t.MinWidth, t.MinHeight))
+	}
+	return issues
+}
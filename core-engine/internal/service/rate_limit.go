@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Limits applied when the tenant policy leaves them at zero
+const (
+	DefaultSessionsPerMinute       = 60
+	DefaultSessionsPerUserPerDay   = 10
+	DefaultPublicRequestsPerMinute = 120
+
+	maxRateLimit = 100000
+)
+
+// RateLimit is a token bucket: Capacity requests at once, refilled evenly over Period
+type RateLimit struct {
+	Capacity int
+	Period   time.Duration
+}
+
+// RateLimitsFor returns the tenant policy with defaults for unset limits
+func RateLimitsFor(policy domain.RateLimitPolicy) domain.RateLimitPolicy {
+	if policy.SessionsPerMinute <= 0 {
+		policy.SessionsPerMinute = DefaultSessionsPerMinute
+	}
+	if policy.SessionsPerUserPerDay <= 0 {
+		policy.SessionsPerUserPerDay = DefaultSessionsPerUserPerDay
+	}
+	if policy.PublicRequestsPerMinute <= 0 {
+		policy.PublicRequestsPerMinute = DefaultPublicRequestsPerMinute
+	}
+	return policy
+}
+
+// ValidateRateLimits checks a tenant policy before saving it
+func ValidateRateLimits(policy domain.RateLimitPolicy) error {
+	for name, v := range map[string]int{
+		"sessions_per_minute":                 policy.SessionsPerMinute,
+		"sessions_per_user_reference_per_day": policy.SessionsPerUserPerDay,
+		"public_requests_per_ip_per_minute":   policy.PublicRequestsPerMinute,
+	} {
+		if v < 0 || v > maxRateLimit {
+			return fmt.Errorf("%s must be between 0 (default) and %d", name, maxRateLimit)
+		}
+	}
+	return nil
+}
+
+// Token bucket kept in a Redis hash. Returns {allowed, milliseconds to wait}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * capacity / period)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * period / capacity)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, wait}
+`)
+
+// RateLimiter shares buckets across engine replicas through Redis. Without
+// Redis, or while it fails, buckets are kept in memory by each replica.
+type RateLimiter struct {
+	Redis *redis.Client // Optional
+
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// Buckets idle for their whole period are full again and can be dropped
+const memoryBucketSweep = 10000
+
+func NewRateLimiter(rdb *redis.Client) *RateLimiter {
+	return &RateLimiter{Redis: rdb, buckets: make(map[string]*memoryBucket)}
+}
+
+// Allow takes a token from the bucket of key. When empty it returns false and
+// how long until the next token.
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration) {
+	if limit.Capacity <= 0 || limit.Period <= 0 {
+		return true, 0
+	}
+	if l.Redis != nil {
+		res, err := tokenBucketScript.Run(ctx, l.Redis, []string{"ratelimit:" + key}, limit.Capacity, limit.Period.Milliseconds(), time.Now().UnixMilli()).Int64Slice()
+		if err == nil && len(res) == 2 {
+			return res[0] == 1, time.Duration(res[1]) * time.Millisecond
+		}
+		log.Printf("WARNING: Rate limiter falling back to memory: %v", err)
+	}
+	return l.allowMemory(key, limit, time.Now())
+}
+
+func (l *RateLimiter) allowMemory(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) >= memoryBucketSweep {
+		for k, b := range l.buckets {
+			if now.Sub(b.updated) > b.period {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	capacity := float64(limit.Capacity)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.period = limit.Period
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*capacity/limit.Period.Seconds())
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) * limit.Period.Seconds() / capacity
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	l := NewRateLimiter(nil)
+	limit := RateLimit{Capacity: 3, Period: time.Minute} // One token every 20s
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after   time.Duration // Since start
+		allowed bool
+		wait    time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 20 * time.Second}, // Empty: a whole token to go
+		{5 * time.Second, false, 15 * time.Second},
+		{20 * time.Second, true, 0}, // One token back
+		{20 * time.Second, false, 20 * time.Second},
+		{10 * time.Minute, true, 0}, // Refilled up to the capacity, not beyond
+		{10 * time.Minute, true, 0},
+		{10 * time.Minute, true, 0},
+		{10 * time.Minute, false, 20 * time.Second},
+	}
+	for i, s := range steps {
+		allowed, wait := l.allowMemory("k", limit, start.Add(s.after))
+		if allowed != s.allowed || wait != s.wait {
+			t.Errorf("request %d at +%s = %v, %s, want %v, %s", i+1, s.after, allowed, wait, s.allowed, s.wait)
+		}
+	}
+}
+
+func TestTokenBucketKeys(t *testing.T) {
+	l := NewRateLimiter(nil)
+	limit := RateLimit{Capacity: 1, Period: time.Hour}
+	now := time.Now()
+	if ok, _ := l.allowMemory("a", limit, now); !ok {
+		t.Fatal("first request of a was refused")
+	}
+	if ok, _ := l.allowMemory("b", limit, now); !ok {
+		t.Error("b shares the bucket of a")
+	}
+	if ok, wait := l.allowMemory("a", limit, now); ok || wait != time.Hour {
+		t.Errorf("second request of a = %v, %s, want refused for 1h", ok, wait)
+	}
+}
+
+func TestTokenBucketDisabled(t *testing.T) {
+	l := NewRateLimiter(nil)
+	for _, limit := range []RateLimit{{Capacity: 0, Period: time.Minute}, {Capacity: 5}} {
+		if ok, wait := l.Allow(t.Context(), "k", limit); !ok || wait != 0 {
+			t.Errorf("Allow with %+v = %v, %s, want no limit", limit, ok, wait)
+		}
+	}
+}
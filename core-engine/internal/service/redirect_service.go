@@ -0,0 +1,154 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// ValidateReturnURL checks that a tenant supplied success/failure URL uses https
+// and points to one of the tenant's allowed domains. Subdomains of an allowed
+// domain are accepted. Plain http is only accepted for localhost, where a
+// tenant develops its integration.
+func ValidateReturnURL(rawURL string, allowedDomains []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("url must be absolute")
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || !isLocalhost(host)) {
+		return errors.New("url must use https")
+	}
+
+	for _, domain := range allowedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %s is not in the tenant allowlist", host)
+}
+
+func isLocalhost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// NormalizeDomain turns user input like "https://Acme.com/" into "acme.com"
+func NormalizeDomain(raw string) (string, error) {
+	d := strings.ToLower(strings.TrimSpace(raw))
+	if strings.Contains(d, "://") {
+		u, err := url.Parse(d)
+		if err != nil {
+			return "", fmt.Errorf("invalid domain %q", raw)
+		}
+		d = u.Hostname()
+	}
+	d = strings.TrimSuffix(d, "/")
+	if d == "" || strings.ContainsAny(d, "/?#@ ") {
+		return "", fmt.Errorf("invalid domain %q", raw)
+	}
+	return d, nil
+}
+
+// NewSigningSecret returns a random hex encoded HMAC key
+func NewSigningSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignRedirect computes the signature the tenant uses to verify a return redirect.
+// Payload: "<session_id>.<status>.<timestamp>"
+func SignRedirect(secret, sessionID string, status domain.SessionStatus, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s.%s.%d", sessionID, status, timestamp)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BuildReturnRedirect returns the URL the user must be sent back to once the flow
+// has finished, or "" if the session is still running or has no return URL.
+func BuildReturnRedirect(session *domain.Session, secret string) (string, error) {
+	if !session.Status.IsFinal() {
+		return "", nil
+	}
+
+	target := session.SuccessURL
+	if session.Status == domain.StatusRejected || session.Status == domain.StatusExpired {
+		target = session.FailureURL
+	}
+	if target == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid return url: %w", err)
+	}
+
+	ts := time.Now().Unix()
+	q := u.Query()
+	q.Set("session_id", session.ID.String())
+	q.Set("status", string(session.Status))
+	q.Set("ts", strconv.FormatInt(ts, 10))
+	q.Set("signature", SignRedirect(secret, session.ID.String(), session.Status, ts))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// FlowBaseURL returns the Secure Flow base URL for a tenant. When the tenant has
+// a custom domain it replaces the host of the configured base URL.
+func FlowBaseURL(baseURL, customDomain string) string {
+	if customDomain == "" {
+		return baseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	u.Scheme = "https"
+	u.Host = customDomain
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// BuildFlowStartURL returns the Secure Flow link for a session
+func BuildFlowStartURL(baseURL, customDomain, token string) string {
+	return fmt.Sprintf("%s/start?token=%s", FlowBaseURL(baseURL, customDomain), url.QueryEscape(token))
+}
+
+// IsAllowedFlowHost reports whether a browser host may serve the tenant's Secure Flow:
+// either the platform host from the base URL or the tenant's own custom domain.
+func IsAllowedFlowHost(host, baseURL, customDomain string) bool {
+	host = strings.ToLower(host)
+	if customDomain != "" && host == strings.ToLower(customDomain) {
+		return true
+	}
+	u, err := url.Parse(baseURL)
+	return err == nil && host == strings.ToLower(u.Hostname())
+}
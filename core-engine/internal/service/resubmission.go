@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+// Resubmission is a reviewer request for the user to redo some steps. It lives
+// in results["resubmission"] while the user goes through them.
+type Resubmission struct {
+	Round       int        `json:"round"`
+	StepIDs     []string   `json:"step_ids"` // In flow order
+	ReasonCode  string     `json:"reason_code"`
+	Notes       string     `json:"notes,omitempty"`
+	RequestedBy string     `json:"requested_by"`
+	RequestedAt time.Time  `json:"requested_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// PreviousSubmission is what had been collected and worked out when a
+// resubmission was requested, kept in results["previous_submissions"] for
+// comparison.
+type PreviousSubmission struct {
+	Round      int                    `json:"round"`
+	StepIDs    []string               `json:"step_ids"`
+	Data       map[string]interface{} `json:"data"`    // Submitted by the user
+	Results    map[string]interface{} `json:"results"` // Engine results
+	ArchivedAt time.Time              `json:"archived_at"`
+}
+
+// Keys that describe the resubmissions themselves and aren't archived
+var resubmissionKeys = []string{"resubmission", "previous_submissions"}
+
+// Engine results each step type produces, recomputed when the step is redone
+var stepResultKeys = map[string][]string{
+	"document_scan":    {"document", "ocr"},
+	"document_capture": {"document", "ocr"},
+	"face_match":       {"biometrics"},
+	"curp_validation":  {"curp_validation"},
+	"barcode_decode":   {"barcode"},
+}
+
+// Risk signal sources checked again on every step
+var recheckedRiskSources = []string{"duplicates", "device"}
+
+// ResubmissionSteps validates the steps a reviewer wants redone and returns them
+// in flow order. Server side checks (CODE_STEP) after the first reopened step
+// are added since their results depend on what the user submits again.
+func ResubmissionSteps(flow *domain.Flow, stepIDs []string) ([]string, error) {
+	if len(stepIDs) == 0 {
+		return nil, fmt.Errorf("no steps to resubmit")
+	}
+	for _, id := range stepIDs {
+		if !slices.ContainsFunc(flow.StepsConfiguration, func(s domain.StepConfig) bool { return s.StepID == id }) {
+			return nil, fmt.Errorf("step %q is not part of the flow", id)
+		}
+	}
+
+	var ordered []string
+	reopened := false
+	for _, step := range flow.StepsConfiguration {
+		requested := slices.Contains(stepIDs, step.StepID)
+		if requested || (reopened && step.Strategy == domain.StrategyCodeStep) {
+			ordered = append(ordered, step.StepID)
+		}
+		reopened = reopened || requested
+	}
+	return ordered, nil
+}
+
+// ReopenSession archives the collected data and the engine results, records
+// the resubmission request and moves the session back to the first step to redo.
+// The results of the steps to redo, their risk signals and the decision are
+// cleared so the policy only sees what the redo produces.
+func ReopenSession(flow *domain.Flow, session *domain.Session, request Resubmission) {
+	if session.Results == nil {
+		session.Results = domain.JSONB{}
+	}
+	var history []PreviousSubmission
+	convertCollected(session.Results["previous_submissions"], &history)
+
+	data := make(map[string]interface{}, len(session.CollectedData))
+	for k, v := range session.CollectedData {
+		data[k] = v
+	}
+	results := make(map[string]interface{}, len(session.Results))
+	for k, v := range session.Results {
+		if !slices.Contains(resubmissionKeys, k) {
+			results[k] = v
+		}
+	}
+	request.Round = len(history) + 1
+	history = append(history, PreviousSubmission{Round: request.Round, StepIDs: request.StepIDs, Data: data, Results: results, ArchivedAt: time.Now()})
+	session.Results["previous_submissions"] = history
+	session.Results["resubmission"] = request
+	clearReopenedResults(flow, session.Results, request.StepIDs)
+
+	// Capture retries start over for the reopened steps
+	var attempts map[string]interface{}
+	if convertCollected(session.Results["capture_attempts"], &attempts) {
+		for _, id := range request.StepIDs {
+			delete(attempts, id)
+		}
+		session.Results["capture_attempts"] = attempts
+	}
+
+	for i, step := range flow.StepsConfiguration {
+		if step.StepID == request.StepIDs[0] {
+			session.CurrentStepIndex = i
+			break
+		}
+	}
+	session.Status = domain.StatusInProgress
+}
+
+// clearReopenedResults drops the engine results that the steps to redo, the
+// per step checks and the final decision produce again
+func clearReopenedResults(flow *domain.Flow, results map[string]interface{}, stepIDs []string) {
+	delete(results, "decision")
+	for _, step := range flow.StepsConfiguration {
+		if slices.Contains(stepIDs, step.StepID) {
+			for _, key := range stepResultKeys[step.Type] {
+				delete(results, key)
+			}
+		}
+	}
+
+	var kept []RiskSignal
+	for _, s := range RiskSignals(results) {
+		if !slices.Contains(stepIDs, s.Source) && !slices.Contains(recheckedRiskSources, s.Source) {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == 0 {
+		delete(results, "risk_signals")
+	} else {
+		results["risk_signals"] = kept
+	}
+}
+
+// NextStepIndex is the step that follows the current one: the next step to
+// redo during a resubmission, otherwise simply the next one in the flow.
+// Finishing the last step to redo completes the resubmission.
+func NextStepIndex(flow *domain.Flow, session *domain.Session) int {
+	var request Resubmission
+	if !convertCollected(session.Results["resubmission"], &request) || request.CompletedAt != nil {
+		return session.CurrentStepIndex + 1
+	}
+	for i := session.CurrentStepIndex + 1; i < len(flow.StepsConfiguration); i++ {
+		if slices.Contains(request.StepIDs, flow.StepsConfiguration[i].StepID) {
+			return i
+		}
+	}
+	now := time.Now()
+	request.CompletedAt = &now
+	session.Results["resubmission"] = request
+	return len(flow.StepsConfiguration)
+}
+
+// ResubmissionExpired reports whether the session waits for a resubmission
+// whose link is no longer valid
+func ResubmissionExpired(session *domain.Session) bool {
+	var request Resubmission
+	if !convertCollected(session.Results["resubmission"], &request) || request.CompletedAt != nil {
+		return false
+	}
+	return !session.Status.IsFinal() && time.Now().After(request.ExpiresAt)
+}
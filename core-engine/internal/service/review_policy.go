@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+var severityRank = map[string]int{RiskLow: 1, RiskMedium: 2, RiskHigh: 3}
+
+// RequiresSecondReview reports whether a reviewer decision must be confirmed by
+// another reviewer before it takes effect. Risk signals come from the session results.
+func RequiresSecondReview(policy domain.ReviewPolicy, decision domain.SessionStatus, results map[string]interface{}) bool {
+	switch decision {
+	case domain.StatusRejected:
+		return policy.FourEyesRejections
+	case domain.StatusApproved:
+		threshold, ok := severityRank[policy.FourEyesApprovalRisk]
+		if !ok {
+			return false
+		}
+		for _, signal := range RiskSignals(results) {
+			if severityRank[signal.Severity] >= threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanOverride reports whether a role may change an APPROVED or REJECTED decision
+func CanOverride(policy domain.ReviewPolicy, role string) bool {
+	roles := policy.OverrideRoles
+	if len(roles) == 0 {
+		roles = []string{"ADMIN"}
+	}
+	return slices.ContainsFunc(roles, func(r string) bool { return strings.EqualFold(r, role) })
+}
+
+// ValidateReviewPolicy reports settings that can't be applied
+func ValidateReviewPolicy(policy domain.ReviewPolicy) []FlowIssue {
+	issues := []FlowIssue{}
+	if policy.FourEyesApprovalRisk != "" {
+		if _, ok := severityRank[policy.FourEyesApprovalRisk]; !ok {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: fmt.Sprintf("review policy risk threshold %q must be low, medium or high", policy.FourEyesApprovalRisk)})
+		}
+	}
+	for _, role := range policy.OverrideRoles {
+		if strings.TrimSpace(role) == "" {
+			issues = append(issues, FlowIssue{Severity: SeverityError, Message: "review policy has an empty override role"})
+		}
+	}
+	return issues
+}
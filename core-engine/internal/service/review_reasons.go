@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+var reasonCodePattern = regexp.MustCompile(`^[a-z0-9_]{1,100}$`)
+
+// DefaultReasonCodes is the taxonomy used by tenants that didn't configure one
+func DefaultReasonCodes() domain.ReasonCodes {
+	approved := []domain.SessionStatus{domain.StatusApproved}
+	rejected := []domain.SessionStatus{domain.StatusRejected}
+	resubmit := []domain.SessionStatus{domain.DecisionResubmit}
+	return domain.ReasonCodes{
+		{Code: "identity_verified", Label: "Identity verified", AppliesTo: approved},
+		{Code: "false_positive", Label: "Automatic checks were wrong", AppliesTo: approved},
+		{Code: "document_fraud", Label: "Forged or altered document", AppliesTo: rejected},
+		{Code: "face_mismatch", Label: "Selfie doesn't match the document", AppliesTo: rejected},
+		{Code: "document_expired", Label: "Expired document", AppliesTo: rejected},
+		{Code: "data_mismatch", Label: "Data doesn't match across sources", AppliesTo: rejected},
+		{Code: "poor_quality", Label: "Captures can't be verified", AppliesTo: []domain.SessionStatus{domain.StatusRejected, domain.DecisionResubmit}},
+		{Code: "incomplete_information", Label: "Missing or unreadable information", AppliesTo: resubmit},
+		{Code: "other", Label: "Other, see notes"},
+	}
+}
+
+// ReasonCodesFor returns the tenant taxonomy, or the default one when empty
+func ReasonCodesFor(codes domain.ReasonCodes) domain.ReasonCodes {
+	if len(codes) == 0 {
+		return DefaultReasonCodes()
+	}
+	return codes
+}
+
+// ValidateReasonCodes checks a taxonomy before saving it
+func ValidateReasonCodes(codes domain.ReasonCodes) error {
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		if !reasonCodePattern.MatchString(c.Code) {
+			return fmt.Errorf("invalid reason code %q: use lowercase letters, digits and _", c.Code)
+		}
+		if seen[c.Code] {
+			return fmt.Errorf("duplicated reason code %q", c.Code)
+		}
+		seen[c.Code] = true
+		if c.Label == "" {
+			return fmt.Errorf("reason code %q has no label", c.Code)
+		}
+		for _, status := range c.AppliesTo {
+			if status != domain.StatusApproved && status != domain.StatusRejected && status != domain.DecisionResubmit {
+				return fmt.Errorf("reason code %q applies to %q, which isn't a review decision", c.Code, status)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckReasonCode reports whether the code exists and can justify the decision
+func CheckReasonCode(codes domain.ReasonCodes, code string, decision domain.SessionStatus) error {
+	for _, c := range codes {
+		if c.Code != code {
+			continue
+		}
+		if len(c.AppliesTo) > 0 && !slices.Contains(c.AppliesTo, decision) {
+			return fmt.Errorf("reason code %q can't justify %s", code, decision)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown reason code %q", code)
+}
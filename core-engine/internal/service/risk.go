@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Risk signal severities
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// RiskSignal is something suspicious noticed while processing a session. They
+// accumulate in the session results (results["risk_signals"]) for reviewers
+// and rules; the user can't write or remove them.
+type RiskSignal struct {
+	Code      string    `json:"code"` // e.g. barcode_front_mismatch
+	Severity  string    `json:"severity"`
+	Source    string    `json:"source"` // Step ID or subsystem that raised it
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RiskSignals returns the signals recorded so far in the session results
+func RiskSignals(results map[string]interface{}) []RiskSignal {
+	var signals []RiskSignal
+	convertCollected(results["risk_signals"], &signals)
+	return signals
+}
+
+// convertCollected reads a collected_data value into dst. Values set during the
+// current request are still Go structs, values loaded from the DB are generic
+// JSON; a JSON round trip handles both.
+func convertCollected(value interface{}, dst interface{}) bool {
+	if value == nil {
+		return false
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, dst) == nil
+}
+
+// AddRiskSignals appends signals to the session results
+func AddRiskSignals(results map[string]interface{}, signals ...RiskSignal) {
+	if len(signals) == 0 {
+		return
+	}
+	now := time.Now()
+	for i := range signals {
+		if signals[i].CreatedAt.IsZero() {
+			signals[i].CreatedAt = now
+		}
+	}
+	results["risk_signals"] = append(RiskSignals(results), signals...)
+}
+
+// AddNewRiskSignals appends the signals whose code the engine hasn't recorded
+// yet, for checks that run again on every step
+func AddNewRiskSignals(results map[string]interface{}, signals ...RiskSignal) {
+	recorded := make(map[string]bool)
+	for _, s := range RiskSignals(results) {
+		recorded[s.Code] = true
+	}
+	var fresh []RiskSignal
+	for _, s := range signals {
+		if !recorded[s.Code] {
+			recorded[s.Code] = true
+			fresh = append(fresh, s)
+		}
+	}
+	AddRiskSignals(results, fresh...)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Limits for tenant supplied data at session creation
+const (
+	MaxPrefillFields       = 50
+	MaxPrefillValueLength  = 1000
+	MaxMetadataKeys        = 20
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+var fieldKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ValidatePrefill checks user data sent by the tenant to pre-fill form steps.
+// Only scalar values are accepted so they can be used as form defaults.
+func ValidatePrefill(prefill map[string]interface{}) error {
+	if len(prefill) > MaxPrefillFields {
+		return fmt.Errorf("prefill supports at most %d fields", MaxPrefillFields)
+	}
+	for k, v := range prefill {
+		if !fieldKeyPattern.MatchString(k) {
+			return fmt.Errorf("prefill key %q is invalid", k)
+		}
+		switch val := v.(type) {
+		case string:
+			if len(val) > MaxPrefillValueLength {
+				return fmt.Errorf("prefill value for %q exceeds %d characters", k, MaxPrefillValueLength)
+			}
+		case float64, bool, nil:
+		default:
+			return fmt.Errorf("prefill value for %q must be a string, number or boolean", k)
+		}
+	}
+	return nil
+}
+
+// ValidateMetadata checks the opaque tenant key/values echoed back in results and webhooks
+func ValidateMetadata(metadata map[string]interface{}) error {
+	if len(metadata) > MaxMetadataKeys {
+		return fmt.Errorf("metadata supports at most %d keys", MaxMetadataKeys)
+	}
+	for k, v := range metadata {
+		if len(k) > MaxMetadataKeyLength || !fieldKeyPattern.MatchString(k) {
+			return fmt.Errorf("metadata key %q is invalid", k)
+		}
+		val, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("metadata value for %q must be a string", k)
+		}
+		if len(val) > MaxMetadataValueLength {
+			return fmt.Errorf("metadata value for %q exceeds %d characters", k, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// ApplyFormDefaults sets the "default" of each form field found in the step
+// base_config from the data already collected for the session.
+func ApplyFormDefaults(baseConfig map[string]interface{}, collected map[string]interface{}) {
+	fields, ok := baseConfig["fields"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := field["id"].(string)
+		if v, ok := collected[id]; ok && id != "" {
+			field["default"] = v
+		}
+	}
+}
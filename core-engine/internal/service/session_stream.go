@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Session changes sent to the real-time streams
+const (
+	StreamSessionCreated       = "session.created"
+	StreamSessionProgressed    = "session.progressed" // A step was completed, same status
+	StreamSessionStatusChanged = "session.status_changed"
+	StreamHandoffCreated       = "session.handoff_created"
+	StreamDeviceChanged        = "session.device_changed"
+)
+
+const streamChannelPrefix = "sessions:"
+
+// Subscribers that fall this many changes behind miss the next ones
+const streamBuffer = 32
+
+// SessionStreamEvent is a session change, published to the stream of its
+// tenant and to the stream of the session itself
+type SessionStreamEvent struct {
+	Event            string               `json:"event"`
+	SessionID        uuid.UUID            `json:"session_id"`
+	UserReference    string               `json:"user_reference"`
+	Status           domain.SessionStatus `json:"status"`
+	PreviousStatus   domain.SessionStatus `json:"previous_status,omitempty"`
+	CurrentStepIndex int                  `json:"current_step_index"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+}
+
+// NewSessionStreamEvent describes the current state of a session
+func NewSessionStreamEvent(event string, session *domain.Session, from domain.SessionStatus) SessionStreamEvent {
+	return SessionStreamEvent{
+		Event:            event,
+		SessionID:        session.ID,
+		UserReference:    session.UserReference,
+		Status:           session.Status,
+		PreviousStatus:   from,
+		CurrentStepIndex: session.CurrentStepIndex,
+		CreatedAt:        session.CreatedAt,
+		UpdatedAt:        time.Now(),
+	}
+}
+
+// SessionStream fans session changes out to the SSE subscribers of every
+// engine replica through Redis pub/sub: each replica relays the Redis channels
+// to its local subscribers. Without Redis, or while publishing fails, changes
+// only reach the subscribers of the replica that made them.
+type SessionStream struct {
+	Redis *redis.Client // Optional
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan []byte]struct{}
+}
+
+func NewSessionStream(rdb *redis.Client) *SessionStream {
+	s := &SessionStream{Redis: rdb, subscribers: make(map[string]map[chan []byte]struct{})}
+	if rdb != nil {
+		go s.relay()
+	}
+	return s
+}
+
+func tenantChannel(tenantID uuid.UUID) string {
+	return streamChannelPrefix + "tenant:" + tenantID.String()
+}
+
+func sessionChannel(sessionID uuid.UUID) string {
+	return streamChannelPrefix + "session:" + sessionID.String()
+}
+
+// Publish sends a change of a session of tenantID to both streams
+func (s *SessionStream) Publish(ctx context.Context, tenantID uuid.UUID, event SessionStreamEvent) {
+	if s == nil {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("ERROR: Failed to encode stream event: %v", err)
+		return
+	}
+	for _, channel := range []string{tenantChannel(tenantID), sessionChannel(event.SessionID)} {
+		if s.Redis != nil {
+			err := s.Redis.Publish(ctx, channel, payload).Err()
+			if err == nil {
+				continue
+			}
+			log.Printf("WARNING: Session stream falling back to memory: %v", err)
+		}
+		s.deliver(channel, payload)
+	}
+}
+
+// SubscribeTenant receives the changes of every session of a tenant. The
+// returned function must be called to stop.
+func (s *SessionStream) SubscribeTenant(tenantID uuid.UUID) (<-chan []byte, func()) {
+	return s.subscribe(tenantChannel(tenantID))
+}
+
+// SubscribeSession receives the changes of one session. The returned function
+// must be called to stop.
+func (s *SessionStream) SubscribeSession(sessionID uuid.UUID) (<-chan []byte, func()) {
+	return s.subscribe(sessionChannel(sessionID))
+}
+
+func (s *SessionStream) subscribe(channel string) (<-chan []byte, func()) {
+	ch := make(chan []byte, streamBuffer)
+	s.mu.Lock()
+	if s.subscribers[channel] == nil {
+		s.subscribers[channel] = make(map[chan []byte]struct{})
+	}
+	s.subscribers[channel][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[channel], ch)
+		if len(s.subscribers[channel]) == 0 {
+			delete(s.subscribers, channel)
+		}
+	}
+}
+
+// deliver never blocks the publisher: a subscriber with a full buffer misses
+// the change
+func (s *SessionStream) deliver(channel string, payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[channel] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+// relay forwards every session channel from Redis to the local subscribers.
+// The Redis client resubscribes by itself after connection errors.
+func (s *SessionStream) relay() {
+	pubsub := s.Redis.PSubscribe(context.Background(), streamChannelPrefix+"*")
+	for msg := range pubsub.Channel() {
+		s.deliver(msg.Channel, []byte(msg.Payload))
+	}
+}
@@ -3,10 +3,16 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/url"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aoricaan/idv-core/internal/infra"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
 )
 
 type StorageService struct {
@@ -17,21 +23,62 @@ func NewStorageService(blob *infra.BlobStorage) *StorageService {
 	return &StorageService{Blob: blob}
 }
 
-func (s *StorageService) GeneratePresignedUploadURL(ctx context.Context, tenantID, sessionToken, filename string) (string, string, error) {
-	// Object Key Structure: tenant_id/session_token/filename
-	objectKey := fmt.Sprintf("%s/%s/%s", tenantID, sessionToken, filename)
+// Upload limits enforced by the presigned POST policy
+const (
+	MinUploadSize = 1024             // 1 KB, rejects empty/placeholder files
+	MaxUploadSize = 10 * 1024 * 1024 // 10 MB
+)
 
-	// Set expiry for the presigned URL
-	expiry := time.Duration(15) * time.Minute
+// AllowedUploadTypes maps accepted MIME types to the extension of the generated object name
+var AllowedUploadTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
 
-	// Generate presigned URL using the SIGNER client (initialized with localhost)
-	// This ensures the Host header in the signature matches what the browser sends.
-	presignedURL, err := s.Blob.SignerClient.PresignedPutObject(ctx, s.Blob.Bucket, objectKey, expiry)
+// SessionObjectPrefix is the key prefix every upload of a session must live under
+func SessionObjectPrefix(tenantID, sessionID string) string {
+	return fmt.Sprintf("%s/%s/", tenantID, sessionID)
+}
+
+// GeneratePresignedUpload returns a presigned POST (URL + form fields) for a new,
+// server named object. The policy pins the key, the content type and the size range.
+func (s *StorageService) GeneratePresignedUpload(ctx context.Context, tenantID, sessionID, contentType string) (string, map[string]string, string, error) {
+	ext, ok := AllowedUploadTypes[contentType]
+	if !ok {
+		return "", nil, "", fmt.Errorf("content type %q is not allowed", contentType)
+	}
+
+	// Object Key Structure: tenant_id/session_id/<random>.<ext>
+	objectKey := SessionObjectPrefix(tenantID, sessionID) + uuid.New().String() + "." + ext
+
+	policy := minio.NewPostPolicy()
+	policy.SetBucket(s.Blob.Bucket)
+	policy.SetKey(objectKey)
+	policy.SetExpires(time.Now().UTC().Add(15 * time.Minute))
+	policy.SetContentType(contentType)
+	policy.SetContentLengthRange(MinUploadSize, MaxUploadSize)
+
+	// Presign with the SIGNER client (initialized with the public endpoint)
+	// This ensures the Host in the signature matches what the browser sends.
+	postURL, formData, err := s.Blob.SignerClient.PresignedPostPolicy(ctx, policy)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate presigned url: %w", err)
+		return "", nil, "", fmt.Errorf("failed to generate presigned post: %w", err)
 	}
 
-	return presignedURL.String(), objectKey, nil
+	return postURL.String(), formData, objectKey, nil
+}
+
+// ObjectExists checks that an uploaded object is actually in the bucket
+func (s *StorageService) ObjectExists(ctx context.Context, objectKey string) (bool, error) {
+	_, err := s.Blob.Client.StatObject(ctx, s.Blob.Bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
 
 func (s *StorageService) GeneratePresignedGetURL(ctx context.Context, objectKey string) (string, error) {
@@ -44,3 +91,79 @@ func (s *StorageService) GeneratePresignedGetURL(ctx context.Context, objectKey
 	}
 	return presignedURL.String(), nil
 }
+
+// PutObject uploads content directly from the backend (e.g. tenant logos)
+func (s *StorageService) PutObject(ctx context.Context, objectKey string, reader io.Reader, size int64, contentType string) error {
+	_, err := s.Blob.Client.PutObject(ctx, s.Blob.Bucket, objectKey, reader, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+// FileDataKeys are the collected_data keys that always hold an object key
+var FileDataKeys = map[string]bool{
+	"document_front": true,
+	"document_back":  true,
+	"selfie":         true,
+}
+
+// Object keys start with the tenant UUID ("<tenant_id>/<session_id>/...")
+var objectKeyPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}/`)
+
+// VerifySessionUploads checks that every file key submitted for a step was
+// uploaded by this session and exists in the bucket. It returns the fields that
+// hold file keys.
+func (s *StorageService) VerifySessionUploads(ctx context.Context, tenantID, sessionID string, data map[string]interface{}) ([]string, error) {
+	prefix := SessionObjectPrefix(tenantID, sessionID)
+	var fields []string
+	for field, v := range data {
+		key, ok := v.(string)
+		if !ok || (!FileDataKeys[field] && !objectKeyPattern.MatchString(key)) {
+			continue
+		}
+
+		if !strings.HasPrefix(key, prefix) || strings.Contains(key, "..") {
+			return nil, &UploadError{Field: field, Reason: "file does not belong to this session"}
+		}
+
+		exists, err := s.ObjectExists(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check upload %s: %w", field, err)
+		}
+		if !exists {
+			return nil, &UploadError{Field: field, Reason: "file was not uploaded"}
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// UploadError is returned when the client references an invalid upload
+type UploadError struct {
+	Field  string
+	Reason string
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// GetObject downloads an object, refusing anything bigger than maxSize
+func (s *StorageService) GetObject(ctx context.Context, objectKey string, maxSize int64) ([]byte, error) {
+	obj, err := s.Blob.Client.GetObject(ctx, s.Blob.Bucket, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("object exceeds %d bytes", maxSize)
+	}
+	return data, nil
+}
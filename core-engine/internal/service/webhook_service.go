@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aoricaan/idv-core/internal/domain"
+)
+
+const WebhookSignatureHeader = "X-IDV-Signature"
+
+// Waits between delivery attempts: a webhook is tried len+1 times at most
+var webhookRetryDelays = []time.Duration{2 * time.Second, 15 * time.Second, time.Minute}
+
+// ErrWebhookTarget is returned for webhook URLs that point to loopback,
+// private, link-local or otherwise non-public addresses
+var ErrWebhookTarget = errors.New("webhook target is not a public address")
+
+// WebhookEvent is the payload delivered to the tenant webhook_url
+type WebhookEvent struct {
+	Event         string                `json:"event"` // e.g. session.review_required
+	SessionID     string                `json:"session_id"`
+	UserReference string                `json:"user_reference"`
+	Status        domain.SessionStatus  `json:"status"`
+	Metadata      domain.JSONB          `json:"metadata,omitempty"`
+	ReasonCode    string                `json:"reason_code,omitempty"` // Of the transition that triggered the event
+	Events        []domain.SessionEvent `json:"events,omitempty"`      // Audit trail, oldest first
+	Resubmission  *ResubmissionNotice   `json:"resubmission,omitempty"`
+	Timestamp     int64                 `json:"timestamp"`
+}
+
+// ResubmissionNotice tells the tenant where the user redoes the requested steps
+type ResubmissionNotice struct {
+	URL       string    `json:"url"`
+	StepIDs   []string  `json:"step_ids"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type WebhookService struct {
+	Client *http.Client
+}
+
+// NewWebhookService returns a service whose client only connects to public
+// addresses, checked on the resolved IP of every connection (redirects
+// included) so a DNS answer can't point a webhook inside our network
+func NewWebhookService() *WebhookService {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}
+	transport := &http.Transport{
+		Proxy:               nil, // A proxy would dial for us, past the check
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &WebhookService{Client: &http.Client{Timeout: 10 * time.Second, Transport: transport}}
+}
+
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookTarget, host)
+	}
+	return nil
+}
+
+func publicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// CheckWebhookURL validates a webhook URL when it is saved: http(s), and a host
+// that is neither localhost nor a non-public IP. Names are checked again on
+// the addresses they resolve to at delivery.
+func CheckWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return errors.New("invalid webhook URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookTarget
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicAddress(ip) {
+		return ErrWebhookTarget
+	}
+	return nil
+}
+
+// NewSessionEvent builds the webhook payload for the current state of a session
+// and its audit trail
+func NewSessionEvent(session *domain.Session, events []domain.SessionEvent) WebhookEvent {
+	event := WebhookEvent{
+		Event:         "session." + strings.ToLower(string(session.Status)),
+		SessionID:     session.ID.String(),
+		UserReference: session.UserReference,
+		Status:        session.Status,
+		Metadata:      session.Metadata,
+		Events:        events,
+		Timestamp:     time.Now().Unix(),
+	}
+	// Device moves do not change the status, the reason is the one of the
+	// last status change
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Kind != domain.EventStatusChange {
+			continue
+		}
+		if events[i].ToStatus == session.Status {
+			event.ReasonCode = events[i].ReasonCode
+		}
+		break
+	}
+	return event
+}
+
+// Notify delivers the event in the background. The body is signed with the tenant
+// signing secret so the receiver can verify it really comes from us. Network
+// errors, timeouts, 429 and 5xx answers are retried a few times; other
+// answers and non-public targets are not.
+func (s *WebhookService) Notify(tenant *domain.Tenant, event WebhookEvent) {
+	if tenant.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("ERROR: Failed to encode webhook: %v", err)
+		return
+	}
+
+	go func() {
+		for attempt := 0; ; attempt++ {
+			err := s.deliver(tenant.WebhookURL, tenant.SigningSecret, body)
+			if err == nil {
+				return
+			}
+			if attempt == len(webhookRetryDelays) || !retryableWebhookError(err) {
+				log.Printf("WARNING: Webhook %s to tenant %s failed after %d attempt(s): %v", event.Event, tenant.ID, attempt+1, err)
+				return
+			}
+			time.Sleep(webhookRetryDelays[attempt])
+		}
+	}()
+}
+
+// webhookStatusError is an answer of the receiver outside 2xx
+type webhookStatusError struct {
+	StatusCode int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+func retryableWebhookError(err error) bool {
+	if errors.Is(err, ErrWebhookTarget) {
+		return false
+	}
+	var status *webhookStatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests || status.StatusCode == http.StatusRequestTimeout
+	}
+	return true
+}
+
+func (s *WebhookService) deliver(webhookURL, secret string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &webhookStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
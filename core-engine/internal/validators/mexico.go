@@ -0,0 +1,181 @@
+// Package validators implements field level checks for identity numbers.
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CURP is the data encoded in a Clave Única de Registro de Población
+type CURP struct {
+	Value       string    `json:"value"`
+	DateOfBirth time.Time `json:"date_of_birth"`
+	Sex         string    `json:"sex"`   // H (hombre), M (mujer) or X
+	State       string    `json:"state"` // Two letter state of birth, NE for foreigners
+}
+
+var (
+	curpPattern         = regexp.MustCompile(`^[A-Z][AEIOUX][A-Z]{2}\d{6}[HMX][A-Z]{2}[B-DF-HJ-NP-TV-Z]{3}[A-Z0-9]\d$`)
+	rfcPattern          = regexp.MustCompile(`^([A-ZÑ&]{3,4})(\d{6})([A-Z0-9]{2}[0-9A])$`)
+	ineCICPattern       = regexp.MustCompile(`^\d{9}$`)
+	ineOCRPattern       = regexp.MustCompile(`^\d{13}$`)
+	claveElectorPattern = regexp.MustCompile(`^[A-Z]{6}\d{8}[HMX]\d{3}$`)
+)
+
+// Mexican states as used in CURP
+var curpStates = map[string]bool{
+	"AS": true, "BC": true, "BS": true, "CC": true, "CL": true, "CM": true, "CS": true, "CH": true,
+	"DF": true, "DG": true, "GT": true, "GR": true, "HG": true, "JC": true, "MC": true, "MN": true,
+	"MS": true, "NT": true, "NL": true, "OC": true, "PL": true, "QT": true, "QR": true, "SP": true,
+	"SL": true, "SR": true, "TC": true, "TS": true, "TL": true, "VZ": true, "YN": true, "ZS": true,
+	"NE": true, // Nacido en el extranjero
+}
+
+// Character values used by the check digits (Ñ is multi-byte, so these are indexed by rune)
+var (
+	curpAlphabet = []rune("0123456789ABCDEFGHIJKLMNÑOPQRSTUVWXYZ")
+	rfcAlphabet  = []rune("0123456789ABCDEFGHIJKLMN&OPQRSTUVWXYZ Ñ")
+)
+
+// Generic RFCs issued by SAT for the general public and foreigners
+var genericRFCs = map[string]bool{"XAXX010101000": true, "XEXX010101000": true}
+
+// ParseCURP validates the structure, date, state and check digit of a CURP
+func ParseCURP(value string) (*CURP, error) {
+	curp := normalize(value)
+	if !curpPattern.MatchString(curp) {
+		return nil, errors.New("CURP format is invalid")
+	}
+	if !curpStates[curp[11:13]] {
+		return nil, fmt.Errorf("CURP state %s is invalid", curp[11:13])
+	}
+
+	// The 17th character tells the century: digit before 2000, letter after
+	century := 1900
+	if c := curp[16]; c >= 'A' && c <= 'Z' {
+		century = 2000
+	}
+	dob, err := parseDate(century, curp[4:10])
+	if err != nil {
+		return nil, errors.New("CURP date of birth is invalid")
+	}
+
+	if digit := checkDigitCURP(curp[:17]); curp[17] != digit {
+		return nil, errors.New("CURP check digit does not match")
+	}
+	return &CURP{Value: curp, DateOfBirth: dob, Sex: curp[10:11], State: curp[11:13]}, nil
+}
+
+func ValidateCURP(value string) error {
+	_, err := ParseCURP(value)
+	return err
+}
+
+func checkDigitCURP(s string) byte {
+	sum := 0
+	for i, r := range []rune(s) {
+		sum += slices.Index(curpAlphabet, r) * (18 - i)
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ValidateRFC checks a persona física (13) or persona moral (12) RFC, including
+// the date and the homoclave check digit.
+func ValidateRFC(value string) error {
+	rfc := normalize(value)
+	if genericRFCs[rfc] {
+		return nil
+	}
+	m := rfcPattern.FindStringSubmatch(rfc)
+	if m == nil {
+		return errors.New("RFC format is invalid")
+	}
+	if _, err := time.Parse("060102", m[2]); err != nil {
+		return errors.New("RFC date is invalid")
+	}
+
+	runes := []rune(rfc)
+	if len(runes) == 12 {
+		runes = append([]rune{' '}, runes...) // Personas morales are padded to 13
+	}
+	if digit := checkDigitRFC(runes[:12]); runes[12] != digit {
+		return errors.New("RFC homoclave check digit does not match")
+	}
+	return nil
+}
+
+func checkDigitRFC(runes []rune) rune {
+	sum := 0
+	for i, r := range runes {
+		sum += slices.Index(rfcAlphabet, r) * (13 - i)
+	}
+	switch mod := sum % 11; {
+	case mod == 0:
+		return '0'
+	case 11-mod == 10:
+		return 'A'
+	default:
+		return rune('0' + 11 - mod)
+	}
+}
+
+// ValidateINECIC checks the 9 digit CIC printed on the back of INE cards (models E and later)
+func ValidateINECIC(value string) error {
+	if !ineCICPattern.MatchString(normalize(value)) {
+		return errors.New("INE CIC must be 9 digits")
+	}
+	return nil
+}
+
+// ValidateINEOCR checks the 13 digit OCR number printed on the back of INE cards
+func ValidateINEOCR(value string) error {
+	if !ineOCRPattern.MatchString(normalize(value)) {
+		return errors.New("INE OCR must be 13 digits")
+	}
+	return nil
+}
+
+// ValidateClaveElector checks the 18 character clave de elector of INE cards
+func ValidateClaveElector(value string) error {
+	clave := normalize(value)
+	if !claveElectorPattern.MatchString(clave) {
+		return errors.New("clave de elector format is invalid")
+	}
+	if _, err := time.Parse("060102", clave[6:12]); err != nil {
+		return errors.New("clave de elector date is invalid")
+	}
+	return nil
+}
+
+// CrossCheckCURPDateOfBirth verifies that the date of birth (YYYY-MM-DD)
+// declared by the user or read from the document matches the CURP.
+func CrossCheckCURPDateOfBirth(curp, dateOfBirth string) error {
+	parsed, err := ParseCURP(curp)
+	if err != nil {
+		return err
+	}
+	dob, err := time.Parse("2006-01-02", strings.TrimSpace(dateOfBirth))
+	if err != nil {
+		return errors.New("date of birth must be YYYY-MM-DD")
+	}
+	if !dob.Equal(parsed.DateOfBirth) {
+		return errors.New("date of birth does not match CURP")
+	}
+	return nil
+}
+
+func normalize(value string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(value), ""))
+}
+
+func parseDate(century int, yymmdd string) (time.Time, error) {
+	t, err := time.Parse("060102", yymmdd)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(century+t.Year()%100, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,104 @@
+package validators
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCURP(t *testing.T) {
+	tests := []struct {
+		curp  string
+		dob   string
+		sex   string
+		state string
+	}{
+		{"HEGG560427MVZRRL04", "1956-04-27", "M", "VZ"}, // RENAPO specimen
+		{"MAHJ280603MSPRRV09", "1928-06-03", "M", "SP"},
+		{"BADD110313HCMLNS06", "1911-03-13", "H", "CM"},
+		{"GOMC050817MJCMRLA3", "2005-08-17", "M", "JC"}, // Letter in position 17: born after 1999
+		{" hegg 560427 mvzrrl04 ", "1956-04-27", "M", "VZ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.curp, func(t *testing.T) {
+			got, err := ParseCURP(tt.curp)
+			if err != nil {
+				t.Fatalf("ParseCURP: %v", err)
+			}
+			if dob := got.DateOfBirth.Format("2006-01-02"); dob != tt.dob || got.Sex != tt.sex || got.State != tt.state {
+				t.Errorf("ParseCURP = %s %s %s, want %s %s %s", dob, got.Sex, got.State, tt.dob, tt.sex, tt.state)
+			}
+		})
+	}
+}
+
+func TestParseCURPInvalid(t *testing.T) {
+	tests := []struct {
+		curp string
+		want string
+	}{
+		{"HEGG560427MVZRRL05", "CURP check digit does not match"},
+		{"BADD110313HCMLNS09", "CURP check digit does not match"},
+		{"GOMC050817MJCMRLA4", "CURP check digit does not match"},
+		{"HEGG560427MXXRRL04", "CURP state XX is invalid"},
+		{"HEGG561327MVZRRL04", "CURP date of birth is invalid"},
+		{"HEGG560427MVZRRL0", "CURP format is invalid"},
+		{"HEGG560427QVZRRL04", "CURP format is invalid"}, // Sex must be H, M or X
+		{"", "CURP format is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.curp, func(t *testing.T) {
+			_, err := ParseCURP(tt.curp)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ParseCURP = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRFC(t *testing.T) {
+	tests := []struct {
+		rfc  string
+		want string // Empty when valid
+	}{
+		{"GODE561231GR8", ""}, // Persona física, SAT example
+		{"SAT970701NN3", ""},  // Persona moral
+		{"MAG041126GT8", ""},
+		{"gode 561231 gr8", ""},
+		{"XAXX010101000", ""}, // Generic, general public
+		{"XEXX010101000", ""}, // Generic, foreigners
+		{"GODE561231GR7", "RFC homoclave check digit does not match"},
+		{"SAT970701NN4", "RFC homoclave check digit does not match"},
+		{"GODE561331GR8", "RFC date is invalid"},
+		{"GODE561231G", "RFC format is invalid"},
+		{"GO1E561231GR8", "RFC format is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rfc, func(t *testing.T) {
+			err := ValidateRFC(tt.rfc)
+			if tt.want == "" && err != nil {
+				t.Errorf("ValidateRFC = %v, want valid", err)
+			}
+			if tt.want != "" && (err == nil || err.Error() != tt.want) {
+				t.Errorf("ValidateRFC = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCURP(t *testing.T) {
+	check := CheckCURP("HEGG560427MVZRRL04", "1956-04-27")
+	if !check.Valid || check.DOBMatches == nil || !*check.DOBMatches {
+		t.Errorf("CheckCURP with the matching date = %+v, want valid", check)
+	}
+	check = CheckCURP("HEGG560427MVZRRL04", "1956-04-28")
+	if check.Valid || check.DOBMatches == nil || *check.DOBMatches {
+		t.Errorf("CheckCURP with another date = %+v, want a mismatch", check)
+	}
+	check = CheckCURP("HEGG560427MVZRRL04", "")
+	if !check.Valid || check.DOBMatches != nil {
+		t.Errorf("CheckCURP without a date = %+v, want valid and no cross-check", check)
+	}
+	if check.CURP.DateOfBirth != time.Date(1956, 4, 27, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("DateOfBirth = %s", check.CURP.DateOfBirth)
+	}
+}
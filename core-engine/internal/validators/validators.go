@@ -0,0 +1,81 @@
+package validators
+
+import "fmt"
+
+// Func validates a single field value
+type Func func(value string) error
+
+// registry maps the names usable in form fields ("validator": "curp") to checks
+var registry = map[string]Func{
+	"curp":          ValidateCURP,
+	"rfc":           ValidateRFC,
+	"ine_cic":       ValidateINECIC,
+	"ine_ocr":       ValidateINEOCR,
+	"clave_elector": ValidateClaveElector,
+}
+
+// Lookup returns the validator registered under name
+func Lookup(name string) (Func, bool) {
+	f, ok := registry[name]
+	return f, ok
+}
+
+// ValidateFormFields runs the validators declared in a form step base_config
+// ("fields": [{"id": "curp", "validator": "curp"}]) against the submitted data.
+// It returns one message per invalid field; empty values are left to "required".
+func ValidateFormFields(baseConfig map[string]interface{}, data map[string]interface{}) []string {
+	fields, ok := baseConfig["fields"].([]interface{})
+	if !ok {
+		return nil
+	}
+	var issues []string
+	for _, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := field["id"].(string)
+		name, _ := field["validator"].(string)
+		if id == "" || name == "" {
+			continue
+		}
+		validate, ok := Lookup(name)
+		if !ok {
+			continue
+		}
+		value, ok := data[id].(string)
+		if !ok || value == "" {
+			continue
+		}
+		if err := validate(value); err != nil {
+			issues = append(issues, fmt.Sprintf("%s: %v", id, err))
+		}
+	}
+	return issues
+}
+
+// CURPCheck is the outcome of the built-in curp_validation step
+type CURPCheck struct {
+	CURP       *CURP    `json:"curp,omitempty"`
+	Valid      bool     `json:"valid"`
+	DOBMatches *bool    `json:"dob_matches,omitempty"` // Nil when no date of birth was available
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// CheckCURP validates a CURP and, when a date of birth is known, cross-checks it
+func CheckCURP(curp, dateOfBirth string) CURPCheck {
+	parsed, err := ParseCURP(curp)
+	if err != nil {
+		return CURPCheck{Errors: []string{err.Error()}}
+	}
+	check := CURPCheck{CURP: parsed, Valid: true}
+	if dateOfBirth != "" {
+		matches := CrossCheckCURPDateOfBirth(curp, dateOfBirth) == nil
+		check.DOBMatches = &matches
+		if !matches {
+			check.Valid = false
+			check.Errors = append(check.Errors, "date of birth does not match CURP")
+		}
+	}
+	return check
+}
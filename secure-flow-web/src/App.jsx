@@ -1,5 +1,7 @@
-import { useState, useEffect } from 'react'
+import { useState, useEffect, useCallback } from 'react'
 import StepRenderer from './components/StepRenderer'
+import HandoffPanel from './components/HandoffPanel'
+import { deviceHeaders, saveSessionSecret } from './device'
 
 function App() {
   const [loading, setLoading] = useState(true)
@@ -7,6 +9,9 @@ function App() {
   const [session, setSession] = useState(null)
   const [nextStep, setNextStep] = useState(null)
   const [token, setToken] = useState(null)
+  const [tenant, setTenant] = useState(null)
+  const [retry, setRetry] = useState(null)
+  const [movedAway, setMovedAway] = useState(false)
 
   useEffect(() => {
     const fetchSession = async () => {
@@ -24,15 +29,27 @@ function App() {
       try {
         // 2. Call Backend
         // Note: in prod this URL should be env var
-        const res = await fetch(`http://localhost:8080/api/v1/sessions?token=${token}`)
+        // A handoff code moves the session to this device, it only works once
+        const handoffCode = params.get('handoff')
+        const query = handoffCode ? `token=${token}&handoff=${encodeURIComponent(handoffCode)}` : `token=${token}`
+        const res = await fetch(`http://localhost:8080/api/v1/sessions?${query}`, { headers: deviceHeaders({}, token) })
+        if (handoffCode) {
+          params.delete('handoff')
+          window.history.replaceState(null, '', `${window.location.pathname}?${params}`)
+        }
 
+        if (res.status === 403) {
+          throw new Error(await res.text())
+        }
         if (!res.ok) {
           throw new Error('Invalid or Expired Session')
         }
 
         const data = await res.json()
+        if (data.device_secret) saveSessionSecret(token, data.device_secret)
         setSession(data.session)
         setNextStep(data.next_step)
+        setTenant(data.tenant)
       } catch (err) {
         setError(err.message)
       } finally {
@@ -52,15 +69,22 @@ function App() {
     try {
       const res = await fetch(`http://localhost:8080/api/v1/sessions/submit?token=${token}`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: deviceHeaders({ 'Content-Type': 'application/json' }, token),
         body: JSON.stringify({ data: stepData || {} })
       })
 
+      if (res.status === 403 || res.status === 409) throw new Error(await res.text())
       if (!res.ok) throw new Error('Failed to submit step')
 
       const data = await res.json()
+      if (data.redirect_url) {
+        // Flow finished: send the user back to the client app
+        window.location.assign(data.redirect_url)
+        return
+      }
       setSession(data.session)
       setNextStep(data.next_step)
+      setRetry(data.retry || null)
     } catch (err) {
       setError(err.message)
     } finally {
@@ -68,19 +92,35 @@ function App() {
     }
   }
 
+  const handleMoved = useCallback(() => setMovedAway(true), [])
+
   if (loading) return <div style={styles.container}>Loading secure session...</div>
   if (error) return <div style={{ ...styles.container, color: 'red' }}>Error: {error}</div>
 
+  const theme = {
+    ...styles.container,
+    fontFamily: tenant?.font_family ? `${tenant.font_family}, system-ui, sans-serif` : styles.container.fontFamily,
+    background: tenant?.background_color,
+    color: tenant?.text_color
+  }
+
   return (
-    <div style={styles.container}>
-      <header style={{ marginBottom: '20px', borderBottom: '1px solid #eee' }}>
-        <h1>IDV SaaS</h1>
-        <small>Session: {session.user_reference}</small>
+    <div style={theme}>
+      <header style={{ marginBottom: '20px', borderBottom: `3px solid ${tenant?.primary_color || '#eee'}` }}>
+        {tenant?.logo_url
+          ? <img src={tenant.logo_url} alt={tenant.name} style={{ maxHeight: '48px' }} />
+          : <h1>{tenant?.name || 'IDV SaaS'}</h1>}
+        <div><small>Session: {session.user_reference}</small></div>
       </header>
 
       <main>
-        {nextStep ? (
-          <StepRenderer step={nextStep} token={token} onStepComplete={handleStepComplete} />
+        {!movedAway && retry && (
+          <div style={{ padding: '10px', marginBottom: '10px', background: '#fff3cd', color: '#856404' }}>
+            <strong>Please try again:</strong> {retry.reasons.join(', ')}
+          </div>
+        )}
+        {movedAway ? null : nextStep ? (
+          <StepRenderer key={`${nextStep.step_id}-${retry?.attempts_left ?? ''}`} step={nextStep} token={token} onStepComplete={handleStepComplete} />
         ) : (
           <div style={{ padding: '20px', background: '#d4edda', color: '#155724' }}>
             <h2>Verification Complete!</h2>
@@ -88,6 +128,18 @@ function App() {
           </div>
         )}
       </main>
+
+      {nextStep && (
+        <section style={{ marginTop: '20px' }}>
+          <HandoffPanel token={token} color={tenant?.primary_color} onMoved={handleMoved} />
+        </section>
+      )}
+
+      {tenant?.privacy_policy_url && (
+        <footer style={{ marginTop: '20px' }}>
+          <a href={tenant.privacy_policy_url} target="_blank" rel="noopener noreferrer">Privacy Policy</a>
+        </footer>
+      )}
     </div>
   )
 }
@@ -1,7 +1,11 @@
 import { useState } from 'react';
+import { deviceHeaders } from '../device';
 
 function DocumentCapture({ config, token, onComplete }) {
+    // Each side is stored under its own field (document_front, document_back)
+    const side = config.side === 'back' ? 'back' : 'front';
     const [uploading, setUploading] = useState(false);
+    const [mrz, setMrz] = useState('');
 
     const handleCapture = async () => {
         setUploading(true);
@@ -16,30 +20,30 @@ function DocumentCapture({ config, token, onComplete }) {
                 ctx.fillRect(0, 0, 640, 480);
                 ctx.fillStyle = '#333';
                 ctx.font = '30px Arial';
-                ctx.fillText(`Front ID - ${new Date().toISOString()}`, 50, 240);
+                ctx.fillText(`${side === 'back' ? 'Back' : 'Front'} ID - ${new Date().toISOString()}`, 50, 240);
                 canvas.toBlob(resolve, 'image/jpeg');
             });
 
-            // 2. Get Presigned URL
-            const filename = `front_${Date.now()}.jpg`;
+            // 2. Get Presigned Upload (server picks the object name)
             const uploadRes = await fetch(`http://localhost:8080/api/v1/sessions/upload-url?token=${token}`, {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ filename, content_type: 'image/jpeg' })
+                headers: deviceHeaders({ 'Content-Type': 'application/json' }, token),
+                body: JSON.stringify({ content_type: 'image/jpeg' })
             });
             if (!uploadRes.ok) throw new Error('Failed to get upload URL');
-            const { upload_url, file_key } = await uploadRes.json();
+            const { upload_url, form_data, file_key } = await uploadRes.json();
 
-            // 3. Upload to MinIO (Direct PUT)
-            const putRes = await fetch(upload_url, {
-                method: 'PUT',
-                body: blob,
-                headers: { 'Content-Type': 'image/jpeg' }
-            });
-            if (!putRes.ok) throw new Error('Failed to upload image to storage');
+            // 3. Upload to MinIO (Presigned POST, policy fields first, file last)
+            const form = new FormData();
+            Object.entries(form_data).forEach(([k, v]) => form.append(k, v));
+            form.append('file', blob);
+            const postRes = await fetch(upload_url, { method: 'POST', body: form });
+            if (!postRes.ok) throw new Error('Failed to upload image to storage');
 
             // 4. Submit Step
-            await onComplete({ document_front: file_key });
+            const data = { [`document_${side}`]: file_key };
+            if (mrz.trim()) data.mrz = mrz;
+            await onComplete(data);
 
         } catch (err) {
             console.error(err);
@@ -57,6 +61,16 @@ function DocumentCapture({ config, token, onComplete }) {
                 [ CAMERA PREVIEW WOULD GO HERE ]
             </div>
 
+            {config.mrz_input && (
+                <textarea
+                    style={styles.mrz}
+                    rows={3}
+                    placeholder="Machine readable zone (the lines with <<< at the bottom of your document)"
+                    value={mrz}
+                    onChange={e => setMrz(e.target.value)}
+                />
+            )}
+
             <button
                 style={{ ...styles.button, opacity: uploading ? 0.5 : 1 }}
                 onClick={handleCapture}
@@ -88,6 +102,12 @@ const styles = {
         marginBottom: '20px',
         borderRadius: '4px'
     },
+    mrz: {
+        width: '100%',
+        fontFamily: 'monospace',
+        marginBottom: '10px',
+        boxSizing: 'border-box'
+    },
     button: {
         width: '100%',
         padding: '12px 20px',
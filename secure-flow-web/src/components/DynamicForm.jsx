@@ -4,8 +4,10 @@ export default function DynamicForm({ config, onComplete }) {
     const fields = config?.fields || [];
     const actions = config?.actions || [{ type: 'submit', label: 'Continue', conditions: [] }];
 
-    // Initialize state
-    const [formData, setFormData] = useState({});
+    // Initialize state (defaults come from data the backend already knows)
+    const [formData, setFormData] = useState(() =>
+        Object.fromEntries(fields.filter(f => f.default !== undefined).map(f => [f.id, f.default]))
+    );
 
     const handleChange = (id, value) => {
         setFormData(prev => ({ ...prev, [id]: value }));
@@ -0,0 +1,92 @@
+import { useState, useEffect } from 'react';
+import { QRCodeSVG } from 'qrcode.react';
+import { deviceHeaders } from '../device';
+import { streamEvents } from '../stream';
+
+// Lets the user continue on a phone: shows a one-time QR code and follows the
+// progress made on the other device until the flow finishes.
+function HandoffPanel({ token, color, onMoved }) {
+    const [handoff, setHandoff] = useState(null);
+    const [progress, setProgress] = useState(null);
+    const [expired, setExpired] = useState(false);
+    const [error, setError] = useState(null);
+
+    const moved = progress && !progress.on_this_device;
+
+    const createHandoff = async () => {
+        setError(null);
+        try {
+            const res = await fetch(`http://localhost:8080/api/v1/sessions/handoff?token=${token}`, {
+                method: 'POST',
+                headers: deviceHeaders({}, token)
+            });
+            if (!res.ok) throw new Error(await res.text());
+            setHandoff(await res.json());
+            setExpired(false);
+        } catch (err) {
+            setError(err.message);
+        }
+    };
+
+    // The code can only be used until it expires
+    useEffect(() => {
+        if (!handoff || moved) return;
+        const timer = setTimeout(() => setExpired(true), new Date(handoff.expires_at) - Date.now());
+        return () => clearTimeout(timer);
+    }, [handoff, moved]);
+
+    // The engine pushes the progress made on the other device
+    useEffect(() => {
+        if (!handoff || progress?.finished) return;
+        const controller = new AbortController();
+        streamEvents(`http://localhost:8080/api/v1/sessions/events?token=${token}`, deviceHeaders({}, token), (event, data) => {
+            if (event !== 'progress') return;
+            setProgress(data);
+            if (!data.on_this_device) onMoved();
+            if (data.redirect_url) window.location.assign(data.redirect_url);
+        }, controller.signal);
+        return () => controller.abort();
+    }, [handoff, progress?.finished, token, onMoved]);
+
+    if (moved) {
+        return (
+            <div style={{ padding: '20px', background: progress.finished ? '#d4edda' : '#e7f1ff' }}>
+                {progress.finished ? (
+                    <h2>Verification Complete!</h2>
+                ) : (
+                    <>
+                        <h2>Continuing on your other device</h2>
+                        <p>{progress.completed_steps} of {progress.total_steps} steps completed. Keep this page open.</p>
+                    </>
+                )}
+            </div>
+        );
+    }
+
+    if (!handoff || expired) {
+        return (
+            <div>
+                {expired && <p>The code expired.</p>}
+                {error && <p style={{ color: 'red' }}>{error}</p>}
+                <button
+                    style={{ padding: '10px 20px', background: 'transparent', color: color || '#007bff', border: '1px solid currentColor', borderRadius: '5px', cursor: 'pointer' }}
+                    onClick={createHandoff}
+                >
+                    {expired ? 'Get a new code' : 'Continue on your phone'}
+                </button>
+            </div>
+        );
+    }
+
+    return (
+        <div style={{ padding: '10px', background: '#e7f1ff' }}>
+            <p>Scan this code with your phone's camera to continue there. It works once, until {new Date(handoff.expires_at).toLocaleTimeString()}.</p>
+            <QRCodeSVG value={handoff.url} size={200} style={{ background: 'white', padding: '8px' }} />
+            <p><small>Or open this link on your phone:</small></p>
+            <input readOnly value={handoff.url} style={{ width: '100%' }} onFocus={(e) => e.target.select()} />
+            <p><small>Waiting for your phone...</small></p>
+        </div>
+    );
+}
+
+export default HandoffPanel;
@@ -1,4 +1,5 @@
 import { useState } from 'react';
+import { deviceHeaders } from '../device';
 
 function SelfieCapture({ config, token, onComplete }) {
     const [uploading, setUploading] = useState(false);
@@ -20,23 +21,21 @@ function SelfieCapture({ config, token, onComplete }) {
                 canvas.toBlob(resolve, 'image/jpeg');
             });
 
-            // 2. Get Presigned URL
-            const filename = `selfie_${Date.now()}.jpg`;
+            // 2. Get Presigned Upload (server picks the object name)
             const uploadRes = await fetch(`http://localhost:8080/api/v1/sessions/upload-url?token=${token}`, {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ filename, content_type: 'image/jpeg' })
+                headers: deviceHeaders({ 'Content-Type': 'application/json' }, token),
+                body: JSON.stringify({ content_type: 'image/jpeg' })
             });
             if (!uploadRes.ok) throw new Error('Failed to get upload URL');
-            const { upload_url, file_key } = await uploadRes.json();
+            const { upload_url, form_data, file_key } = await uploadRes.json();
 
-            // 3. Upload to MinIO (Direct PUT)
-            const putRes = await fetch(upload_url, {
-                method: 'PUT',
-                body: blob,
-                headers: { 'Content-Type': 'image/jpeg' }
-            });
-            if (!putRes.ok) throw new Error('Failed to upload image to storage');
+            // 3. Upload to MinIO (Presigned POST, policy fields first, file last)
+            const form = new FormData();
+            Object.entries(form_data).forEach(([k, v]) => form.append(k, v));
+            form.append('file', blob);
+            const postRes = await fetch(upload_url, { method: 'POST', body: form });
+            if (!postRes.ok) throw new Error('Failed to upload image to storage');
 
             // 4. Submit Step
             await onComplete({ selfie: file_key });
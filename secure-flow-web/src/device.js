@@ -0,0 +1,62 @@
+// Identifies this browser to the engine (X-Device-Fingerprint), so it can tell
+// when a session link is opened from several devices
+const STORAGE_KEY = 'idv_device_id'
+
+function traitsHash() {
+  const traits = [
+    navigator.userAgent,
+    navigator.language,
+    navigator.platform,
+    navigator.hardwareConcurrency,
+    screen.width + 'x' + screen.height + 'x' + screen.colorDepth,
+    Intl.DateTimeFormat().resolvedOptions().timeZone,
+  ].join('|')
+  let hash = 5381
+  for (let i = 0; i < traits.length; i++) {
+    hash = ((hash << 5) + hash + traits.charCodeAt(i)) >>> 0
+  }
+  return 'traits-' + hash.toString(16)
+}
+
+export function deviceFingerprint() {
+  try {
+    let id = localStorage.getItem(STORAGE_KEY)
+    if (!id) {
+      id = crypto.randomUUID()
+      localStorage.setItem(STORAGE_KEY, id)
+    }
+    return id
+  } catch {
+    // Storage disabled (private mode): fall back to browser traits
+    return traitsHash()
+  }
+}
+
+// The engine binds a session to the first device that opens it and returns a
+// secret that must accompany every later call (X-Session-Secret)
+const secretKey = (token) => 'idv_session_secret:' + token
+const memorySecrets = {}
+
+export function saveSessionSecret(token, secret) {
+  memorySecrets[token] = secret
+  try {
+    localStorage.setItem(secretKey(token), secret)
+  } catch {
+    // Storage disabled: the secret lives until the page is reloaded
+  }
+}
+
+function sessionSecret(token) {
+  try {
+    return localStorage.getItem(secretKey(token)) || memorySecrets[token]
+  } catch {
+    return memorySecrets[token]
+  }
+}
+
+export function deviceHeaders(headers = {}, token) {
+  const result = { ...headers, 'X-Device-Fingerprint': deviceFingerprint() }
+  const secret = token && sessionSecret(token)
+  if (secret) result['X-Session-Secret'] = secret
+  return result
+}